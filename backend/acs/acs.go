@@ -0,0 +1,83 @@
+// Package acs reads the Census Bureau's American Community Survey 5-year
+// summary files: the sequence and table number lookup (the table shells),
+// the comma-separated geography file and the estimate and margin of error
+// sequence files.  Like package sf1, readers return errors rather than
+// exiting, and malformed lines are reported as *LineError.
+package acs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// File names take the vintage (the last year of the 5-year period), the
+// USPS code and, for sequence files, the sequence number: e20135in0001000.txt
+// holds the estimates for sequence 1 of Indiana's 2009-2013 release.
+const EstimateFileTemplate = "e%s5%s%04d000.txt"
+const MOEFileTemplate = "m%s5%s%04d000.txt"
+const GeoFileTemplate = "g%s5%s.csv"
+
+var GeoFileRegexp = regexp.MustCompile(`^g(\d{4})5([a-z]{2})\.csv$`)
+
+// The lookup file has been published under several names.
+var LookupFileNames = []string{
+	"ACS_5yr_Seq_Table_Number_Lookup.txt",
+	"ACS_5yr_Seq_Table_Number_Lookup.csv",
+	"Sequence_Number_and_Table_Number_Lookup.txt",
+}
+
+// A malformed line in an ACS file.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Returns the name of a state's estimate file for a sequence.
+func EstimateFileName(vintage string, state string, sequence int) string {
+	return fmt.Sprintf(EstimateFileTemplate, vintage, state, sequence)
+}
+
+// Returns the name of a state's margin of error file for a sequence.
+func MOEFileName(vintage string, state string, sequence int) string {
+	return fmt.Sprintf(MOEFileTemplate, vintage, state, sequence)
+}
+
+// Returns the name of a state's geography file.
+func GeoFileName(vintage string, state string) string {
+	return fmt.Sprintf(GeoFileTemplate, vintage, state)
+}
+
+// Reads the non-empty lines of a sequence file as comma-separated fields,
+// counting all of them.
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	return &lineReader{scanner: scanner}
+}
+
+func (lr *lineReader) next() ([]string, int, error) {
+	for lr.scanner.Scan() {
+		lr.line++
+		if line := lr.scanner.Text(); len(line) > 0 {
+			return strings.Split(line, ","), lr.line, nil
+		}
+	}
+	if err := lr.scanner.Err(); err != nil {
+		return nil, lr.line, err
+	}
+
+	return nil, lr.line, io.EOF
+}
@@ -0,0 +1,87 @@
+package acs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/camgunz/mapblue/backend/source"
+)
+
+// The columns of the 5-year geography file, in order.  Empty names are the
+// layout's BLANK filler columns.
+var GeoFields = []string{
+	"FILEID", "STUSAB", "SUMLEVEL", "COMPONENT", "LOGRECNO", "US",
+	"REGION", "DIVISION", "STATECE", "STATE", "COUNTY", "COUSUB", "PLACE",
+	"TRACT", "BLKGRP", "CONCIT", "AIANHH", "AIANHHFP", "AIHHTLI", "AITSCE",
+	"AITS", "ANRC", "CBSA", "CSA", "METDIV", "MACC", "MEMI", "NECTA",
+	"CNECTA", "NECTADIV", "UA", "", "CDCURR", "SLDU", "SLDL", "", "",
+	"ZCTA5", "SUBMCD", "SDELM", "SDSEC", "SDUNI", "UR", "PCI", "", "",
+	"PUMA5", "", "GEOID", "NAME", "BTTR", "BTBG", "",
+}
+
+// Trailing BLANK columns are sometimes left off.
+var geoMinimumFieldCount = len(GeoFields) - 1
+
+// A geography record.  Values holds one value per entry in GeoFields.
+type GeoRecord struct {
+	Line   int
+	Values []string
+}
+
+// Returns the value of the named field, or "" if there is no such field.
+func (record *GeoRecord) Value(name string) string {
+	for fi, fieldName := range GeoFields {
+		if fieldName == name {
+			return record.Values[fi]
+		}
+	}
+
+	return ""
+}
+
+func (record *GeoRecord) LogRecNo() string {
+	return record.Value("LOGRECNO")
+}
+
+func (record *GeoRecord) StUSAB() string {
+	return record.Value("STUSAB")
+}
+
+// Reads the records of a geography file.
+type GeoReader struct {
+	reader *csv.Reader
+	line   int
+}
+
+func NewGeoReader(r io.Reader) *GeoReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return &GeoReader{reader: reader}
+}
+
+// Returns the next record, or io.EOF after the last one.  A line with the
+// wrong number of fields returns a *LineError; reading can continue past it.
+func (gr *GeoReader) Next() (*GeoRecord, error) {
+	fields, err := gr.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	gr.line++
+
+	if len(fields) < geoMinimumFieldCount || len(fields) > len(GeoFields) {
+		return nil, &LineError{gr.line, fmt.Errorf(
+			"%d fields, expected %d", len(fields), len(GeoFields),
+		)}
+	}
+
+	values := make([]string, len(GeoFields))
+	for fi, field := range fields {
+		values[fi] = strings.TrimSpace(source.DecodeLatin1(field))
+	}
+
+	return &GeoRecord{Line: gr.line, Values: values}, nil
+}
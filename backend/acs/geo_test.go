@@ -0,0 +1,84 @@
+package acs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// A geography file line with the given values, quoting the ones holding
+// commas as the census does.
+func geoLine(values map[string]string, fieldCount int) string {
+	fields := make([]string, fieldCount)
+	for fi := range fields {
+		value := values[GeoFields[fi]]
+		if strings.Contains(value, ",") {
+			value = `"` + value + `"`
+		}
+		fields[fi] = value
+	}
+
+	return strings.Join(fields, ",") + "\n"
+}
+
+func TestGeoReader(t *testing.T) {
+	county := map[string]string{
+		"FILEID":   "ACSSF",
+		"STUSAB":   "nm",
+		"SUMLEVEL": "050",
+		"LOGRECNO": "0000013",
+		"STATE":    "35",
+		"COUNTY":   "013",
+		"GEOID":    "05000US35013",
+		// Names are Latin-1
+		"NAME": "Do\xf1a Ana County, New Mexico",
+	}
+	state := map[string]string{
+		"FILEID":   "ACSSF",
+		"STUSAB":   "nm",
+		"SUMLEVEL": "040",
+		"LOGRECNO": "0000001",
+		"GEOID":    "04000US35",
+		"NAME":     "New Mexico",
+	}
+	data := geoLine(county, len(GeoFields)) +
+		"ACSSF,nm,050\n" +
+		// The trailing BLANK column can be left off
+		geoLine(state, len(GeoFields)-1)
+	reader := NewGeoReader(strings.NewReader(data))
+
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Value("NAME") != "Doña Ana County, New Mexico" {
+		t.Errorf("NAME = %q", record.Value("NAME"))
+	}
+	if record.StUSAB() != "nm" || record.LogRecNo() != "0000013" ||
+		record.Value("SUMLEVEL") != "050" ||
+		record.Value("GEOID") != "05000US35013" ||
+		record.Value("PLACE") != "" || record.Value("NOSUCHFIELD") != "" {
+		t.Errorf("got %q", record.Values)
+	}
+
+	var lineError *LineError
+	if _, err := reader.Next(); !errors.As(err, &lineError) {
+		t.Fatalf("short record: got %v, want a *LineError", err)
+	}
+	if lineError.Line != 2 {
+		t.Errorf("short record error on line %d, want 2", lineError.Line)
+	}
+
+	record, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Line != 3 || record.Value("NAME") != "New Mexico" ||
+		len(record.Values) != len(GeoFields) {
+		t.Errorf("line %d: got %q", record.Line, record.Values)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("got %v after the last record, want io.EOF", err)
+	}
+}
@@ -0,0 +1,191 @@
+package acs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/camgunz/mapblue/backend/source"
+)
+
+// Columns of the lookup file.
+const (
+	lookupTableID = iota + 1
+	lookupSequence
+	lookupLineNumber
+	lookupStartPosition
+	lookupTotalCells
+	lookupSequenceCells
+	lookupTitle
+	lookupColumnCount
+)
+
+// A table's data cells, in order.  Lines with fractional numbers in the
+// lookup file are headings with no data and are left out.
+type Line struct {
+	Number int
+	Title  string
+}
+
+// The cells of a table held in one sequence.  StartPosition counts from 1 and
+// includes the identification columns, as in the lookup file.
+type TablePart struct {
+	Sequence      int
+	StartPosition int
+	CellCount     int
+}
+
+// A table shell: the table's name (the lowercased table ID, e.g. "b19013"),
+// title, universe and cells, and where its cells are in the sequence files.
+// Most tables fit in one sequence; the largest span several.
+type Table struct {
+	Name     string
+	Title    string
+	Universe string
+	Parts    []TablePart
+	Lines    []Line
+}
+
+type Lookup struct {
+	Tables    []*Table
+	Sequences []int
+}
+
+// Reads the sequence and table number lookup file.
+func ReadLookup(r io.Reader) (*Lookup, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	lookup := &Lookup{}
+	tables := make(map[string]*Table)
+	totalCells := make(map[string]int)
+	sequences := make(map[int]bool)
+
+	for lineNumber := 1; ; lineNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if lineNumber == 1 {
+			continue // header
+		}
+		if len(record) < lookupColumnCount {
+			return nil, &LineError{lineNumber, fmt.Errorf(
+				"%d fields, expected %d", len(record), lookupColumnCount,
+			)}
+		}
+		for fi, field := range record {
+			record[fi] = strings.TrimSpace(source.DecodeLatin1(field))
+		}
+
+		tableName := strings.ToLower(record[lookupTableID])
+		sequence, err := strconv.Atoi(record[lookupSequence])
+		if err != nil {
+			return nil, &LineError{lineNumber, fmt.Errorf(
+				"bad sequence number %q", record[lookupSequence],
+			)}
+		}
+		table, ok := tables[tableName]
+		if !ok {
+			table = &Table{Name: tableName}
+			tables[tableName] = table
+			lookup.Tables = append(lookup.Tables, table)
+		}
+
+		if len(record[lookupStartPosition]) > 0 {
+			startPosition, err := strconv.Atoi(record[lookupStartPosition])
+			if err != nil {
+				return nil, &LineError{lineNumber, fmt.Errorf(
+					"bad start position %q", record[lookupStartPosition],
+				)}
+			}
+			if len(table.Title) == 0 {
+				table.Title = record[lookupTitle]
+			}
+			if cells, ok := parseCellCount(record[lookupTotalCells]); ok {
+				totalCells[tableName] = cells
+			}
+			table.Parts = append(table.Parts, TablePart{
+				Sequence:      sequence,
+				StartPosition: startPosition,
+			})
+			if !sequences[sequence] {
+				sequences[sequence] = true
+				lookup.Sequences = append(lookup.Sequences, sequence)
+			}
+			continue
+		}
+
+		if len(record[lookupLineNumber]) == 0 {
+			if strings.HasPrefix(record[lookupTitle], "Universe:") {
+				table.Universe = strings.TrimSpace(
+					strings.TrimPrefix(record[lookupTitle], "Universe:"),
+				)
+			}
+			continue
+		}
+
+		number, err := strconv.Atoi(record[lookupLineNumber])
+		if err != nil {
+			continue // a heading such as line 0.5
+		}
+		if len(table.Parts) == 0 ||
+			table.Parts[len(table.Parts)-1].Sequence != sequence {
+			return nil, &LineError{lineNumber, fmt.Errorf(
+				"line %d of table %s has no start position in sequence %d",
+				number, tableName, sequence,
+			)}
+		}
+		table.Parts[len(table.Parts)-1].CellCount++
+		table.Lines = append(table.Lines, Line{
+			Number: number,
+			Title:  record[lookupTitle],
+		})
+	}
+
+	for _, table := range lookup.Tables {
+		cells, ok := totalCells[table.Name]
+		if ok && cells != len(table.Lines) {
+			return nil, fmt.Errorf(
+				"table %s has %d cells, the lookup file says %d",
+				table.Name, len(table.Lines), cells,
+			)
+		}
+	}
+
+	return lookup, nil
+}
+
+// Parses "17 CELLS" or "1 CELL".
+func parseCellCount(value string) (int, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	cells, err := strconv.Atoi(fields[0])
+
+	return cells, err == nil
+}
+
+// Returns the number of fields each sequence file's lines need to hold every
+// table in it.
+func (lookup *Lookup) SequenceFieldCounts() map[int]int {
+	fieldCounts := make(map[int]int)
+
+	for _, table := range lookup.Tables {
+		for _, part := range table.Parts {
+			fieldCount := part.StartPosition + part.CellCount - 1
+			if fieldCount > fieldCounts[part.Sequence] {
+				fieldCounts[part.Sequence] = fieldCount
+			}
+		}
+	}
+
+	return fieldCounts
+}
@@ -0,0 +1,125 @@
+package acs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lookupHeader = "File ID,Table ID,Sequence Number,Line Number," +
+	"Start Position,Total Cells in Table,Total Cells in Sequence," +
+	"Table Title,Subject Area\n"
+
+func TestReadLookup(t *testing.T) {
+	data := lookupHeader +
+		"ACSSF,B01001,0002,,7,3 CELLS,2,SEX BY AGE,Age-Sex\n" +
+		"ACSSF,B01001,0002,,,,,Universe:  Total population,\n" +
+		"ACSSF,B01001,0002,1,,,,Total:,\n" +
+		"ACSSF,B01001,0002,2,,,,Male:,\n" +
+		"ACSSF,B01001,0002,2.5,,,,Under 5 years:,\n" +
+		"ACSSF,B01001,0003,,7,,1,SEX BY AGE,Age-Sex\n" +
+		"ACSSF,B01001,0003,3,,,,Female:,\n" +
+		"ACSSF,B19013,0003,,8,1 CELL,1," +
+		"\"MEDIAN HOUSEHOLD INCOME IN THE PAST 12 MONTHS\",Income\n" +
+		"ACSSF,B19013,0003,,,,,Universe:  Households,\n" +
+		"ACSSF,B19013,0003,1,,,,Median household income,\n"
+
+	lookup, err := ReadLookup(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Table{
+		{
+			Name:     "b01001",
+			Title:    "SEX BY AGE",
+			Universe: "Total population",
+			Parts: []TablePart{
+				{Sequence: 2, StartPosition: 7, CellCount: 2},
+				{Sequence: 3, StartPosition: 7, CellCount: 1},
+			},
+			Lines: []Line{{1, "Total:"}, {2, "Male:"}, {3, "Female:"}},
+		},
+		{
+			Name:     "b19013",
+			Title:    "MEDIAN HOUSEHOLD INCOME IN THE PAST 12 MONTHS",
+			Universe: "Households",
+			Parts: []TablePart{
+				{Sequence: 3, StartPosition: 8, CellCount: 1},
+			},
+			Lines: []Line{{1, "Median household income"}},
+		},
+	}
+	if !reflect.DeepEqual(lookup.Tables, want) {
+		for ti, table := range lookup.Tables {
+			t.Errorf("table %d = %+v", ti, table)
+		}
+	}
+	if !reflect.DeepEqual(lookup.Sequences, []int{2, 3}) {
+		t.Errorf("sequences = %v, want [2 3]", lookup.Sequences)
+	}
+	fieldCounts := lookup.SequenceFieldCounts()
+	if !reflect.DeepEqual(fieldCounts, map[int]int{2: 8, 3: 8}) {
+		t.Errorf("sequence field counts = %v, want 2: 8, 3: 8", fieldCounts)
+	}
+}
+
+func TestReadLookupErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		line int
+		err  string
+	}{
+		{
+			name: "bad sequence",
+			data: "ACSSF,B19013,x,,8,1 CELL,1,MEDIAN HOUSEHOLD INCOME,Income\n",
+			line: 2,
+		},
+		{
+			name: "bad start position",
+			data: "ACSSF,B19013,0003,,x,1 CELL,1,MEDIAN HOUSEHOLD INCOME,\n",
+			line: 2,
+		},
+		{
+			name: "too few fields",
+			data: "ACSSF,B19013,0003\n",
+			line: 2,
+		},
+		{
+			name: "line without a start position",
+			data: "ACSSF,B19013,0003,,8,1 CELL,1,MEDIAN HOUSEHOLD INCOME,\n" +
+				"ACSSF,B19013,0004,1,,,,Median household income,\n",
+			line: 3,
+		},
+		{
+			name: "cell count",
+			data: "ACSSF,B19013,0003,,8,2 CELLS,1,MEDIAN HOUSEHOLD INCOME,\n" +
+				"ACSSF,B19013,0003,1,,,,Median household income,\n",
+			err: "table b19013 has 1 cells, the lookup file says 2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadLookup(strings.NewReader(lookupHeader + test.data))
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if len(test.err) != 0 {
+				if err.Error() != test.err {
+					t.Errorf("got %s, want %s", err, test.err)
+				}
+				return
+			}
+			var lineError *LineError
+			if !errors.As(err, &lineError) {
+				t.Fatalf("got %v, want a *LineError", err)
+			}
+			if lineError.Line != test.line {
+				t.Errorf("error on line %d, want %d", lineError.Line, test.line)
+			}
+		})
+	}
+}
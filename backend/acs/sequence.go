@@ -0,0 +1,210 @@
+package acs
+
+import (
+	"fmt"
+	"io"
+)
+
+// Every sequence file line starts with FILEID, FILETYPE, STUSAB, CHARITER,
+// SEQUENCE and LOGRECNO.
+const SequenceKeyColumns = 6
+
+var SequenceKeyColumnNames = []string{
+	"fileid", "filetype", "stusab", "chariter", "sequence", "logrecno",
+}
+
+// A line of a sequence file.
+type SequenceRow struct {
+	Line   int
+	Fields []string
+}
+
+func (row *SequenceRow) StUSAB() string {
+	return row.Fields[2]
+}
+
+func (row *SequenceRow) LogRecNo() string {
+	return row.Fields[5]
+}
+
+// Returns the cells of a table part.  Suppressed and missing values, written
+// as ".", are returned as "".
+func (row *SequenceRow) PartValues(tableName string, part TablePart) ([]string, error) {
+	startIndex := part.StartPosition - 1
+	endIndex := startIndex + part.CellCount
+	if startIndex < SequenceKeyColumns || endIndex > len(row.Fields) {
+		return nil, &LineError{row.Line, fmt.Errorf(
+			"row value indices for %s out of range: [%d:%d] (%d)",
+			tableName, startIndex, endIndex, len(row.Fields),
+		)}
+	}
+
+	values := make([]string, part.CellCount)
+	for vi, value := range row.Fields[startIndex:endIndex] {
+		if value != "." {
+			values[vi] = value
+		}
+	}
+
+	return values, nil
+}
+
+// Reads the lines of an estimate or margin of error sequence file.  The
+// files are plain comma-separated values without quoting, like SF1 data
+// segments.
+type SequenceReader struct {
+	lines *lineReader
+}
+
+func NewSequenceReader(r io.Reader) *SequenceReader {
+	return &SequenceReader{lines: newLineReader(r)}
+}
+
+// Returns the next row, or io.EOF after the last one.  A line without the
+// identification columns returns a *LineError; reading can continue past
+// it.
+func (sr *SequenceReader) Next() (*SequenceRow, error) {
+	fields, lineNumber, err := sr.lines.next()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < SequenceKeyColumns {
+		return nil, &LineError{lineNumber, fmt.Errorf(
+			"%d fields, expected at least %d",
+			len(fields), SequenceKeyColumns,
+		)}
+	}
+
+	return &SequenceRow{Line: lineNumber, Fields: fields}, nil
+}
+
+// The estimate and margin of error files of one table part.
+type Source struct {
+	Estimates io.Reader
+	MOEs      io.Reader
+}
+
+// A table's row: the identification columns, then each cell's estimate
+// followed by its margin of error.
+type TableRow struct {
+	Line     int
+	StUSAB   string
+	LogRecNo string
+	Values   []string
+}
+
+// Reads a table's rows out of its estimate and margin of error files, in
+// step.  A table spanning several sequences needs one Source per part.
+type TableReader struct {
+	Table     *Table
+	estimates []*SequenceReader
+	moes      []*SequenceReader
+}
+
+func NewTableReader(table *Table, sources []Source) (*TableReader, error) {
+	if len(sources) != len(table.Parts) {
+		return nil, fmt.Errorf("table %s has %d parts, got %d sources",
+			table.Name, len(table.Parts), len(sources),
+		)
+	}
+
+	tr := &TableReader{Table: table}
+	for _, source := range sources {
+		tr.estimates = append(tr.estimates, NewSequenceReader(source.Estimates))
+		tr.moes = append(tr.moes, NewSequenceReader(source.MOEs))
+	}
+
+	return tr, nil
+}
+
+// Returns the next row, or io.EOF after the last one.  Rows of the estimate
+// and margin of error files (and of every part) must have the same LOGRECNO;
+// the files can't be read further once they don't.
+func (tr *TableReader) Next() (*TableRow, error) {
+	var tableRow *TableRow
+	estimates := []string{}
+	moes := []string{}
+
+	for pi, part := range tr.Table.Parts {
+		estimateRow, err := tr.estimates[pi].Next()
+		if err != nil {
+			return nil, err
+		}
+		moeRow, err := tr.moes[pi].Next()
+		if err == io.EOF {
+			return nil, &LineError{estimateRow.Line, fmt.Errorf(
+				"margin of error file for %s ends early", tr.Table.Name,
+			)}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tableRow == nil {
+			tableRow = &TableRow{
+				Line:     estimateRow.Line,
+				StUSAB:   estimateRow.StUSAB(),
+				LogRecNo: estimateRow.LogRecNo(),
+			}
+			tableRow.Values = append(
+				tableRow.Values, estimateRow.Fields[:SequenceKeyColumns]...,
+			)
+		}
+		if estimateRow.LogRecNo() != tableRow.LogRecNo ||
+			moeRow.LogRecNo() != tableRow.LogRecNo {
+			return nil, fmt.Errorf(
+				"%s: line %d: LOGRECNO %s, %s (margin of error) and %s "+
+					"(sequence %d) do not match",
+				tr.Table.Name, estimateRow.Line, tableRow.LogRecNo,
+				moeRow.LogRecNo(), estimateRow.LogRecNo(), part.Sequence,
+			)
+		}
+
+		partEstimates, err := estimateRow.PartValues(tr.Table.Name, part)
+		if err != nil {
+			return nil, err
+		}
+		partMOEs, err := moeRow.PartValues(tr.Table.Name, part)
+		if err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, partEstimates...)
+		moes = append(moes, partMOEs...)
+	}
+
+	for ci := range estimates {
+		tableRow.Values = append(tableRow.Values, estimates[ci], moes[ci])
+	}
+
+	return tableRow, nil
+}
+
+// Returns the column names for a table: the identification columns, then
+// each cell's estimate and margin of error, named as in the Census API
+// ("b19013_001e" and "b19013_001m").
+func ColumnNames(table *Table) []string {
+	columnNames := append([]string{}, SequenceKeyColumnNames...)
+	for _, line := range table.Lines {
+		columnNames = append(columnNames,
+			fmt.Sprintf("%s_%03de", table.Name, line.Number),
+			fmt.Sprintf("%s_%03dm", table.Name, line.Number),
+		)
+	}
+
+	return columnNames
+}
+
+// Returns the column labels for a table, in ColumnNames' order: each cell's
+// title from the lookup file, marked as an estimate or a margin of error.
+// The identification columns have no label.
+func ColumnLabels(table *Table) []string {
+	columnLabels := make([]string, len(SequenceKeyColumnNames))
+	for _, line := range table.Lines {
+		columnLabels = append(columnLabels,
+			line.Title+" (estimate)",
+			line.Title+" (margin of error)",
+		)
+	}
+
+	return columnLabels
+}
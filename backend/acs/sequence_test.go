@@ -0,0 +1,159 @@
+package acs
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTableReader(t *testing.T) {
+	table := &Table{
+		Name: "b19013",
+		Parts: []TablePart{
+			{Sequence: 57, StartPosition: 7, CellCount: 2},
+			{Sequence: 58, StartPosition: 8, CellCount: 1},
+		},
+		Lines: []Line{
+			{1, "Median household income"},
+			{2, "White alone"},
+			{3, "Black alone"},
+		},
+	}
+	sources := []Source{
+		{
+			Estimates: strings.NewReader(
+				"ACSSF,2013e5,in,000,0057,0000001,47529,.\n" +
+					"ACSSF,2013e5,in,000,0057,0000002,51000,52000\n",
+			),
+			MOEs: strings.NewReader(
+				"ACSSF,2013m5,in,000,0057,0000001,235,.\n" +
+					"ACSSF,2013m5,in,000,0057,0000002,600,700\n",
+			),
+		},
+		{
+			Estimates: strings.NewReader(
+				"ACSSF,2013e5,in,000,0058,0000001,9,30013\n" +
+					"ACSSF,2013e5,in,000,0058,0000002,9,31000\n",
+			),
+			MOEs: strings.NewReader(
+				"ACSSF,2013m5,in,000,0058,0000001,9,611\n" +
+					"ACSSF,2013m5,in,000,0058,0000002,9,800\n",
+			),
+		},
+	}
+
+	wantColumns := []string{
+		"fileid", "filetype", "stusab", "chariter", "sequence", "logrecno",
+		"b19013_001e", "b19013_001m", "b19013_002e", "b19013_002m",
+		"b19013_003e", "b19013_003m",
+	}
+	if columns := ColumnNames(table); !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("columns = %v, want %v", columns, wantColumns)
+	}
+	labels := ColumnLabels(table)
+	if len(labels) != len(wantColumns) ||
+		labels[6] != "Median household income (estimate)" ||
+		labels[7] != "Median household income (margin of error)" {
+		t.Errorf("labels = %q", labels)
+	}
+
+	reader, err := NewTableReader(table, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := []*TableRow{
+		{Line: 1, StUSAB: "in", LogRecNo: "0000001", Values: []string{
+			"ACSSF", "2013e5", "in", "000", "0057", "0000001",
+			// Each estimate is followed by its margin of error; "." is
+			// suppressed
+			"47529", "235", "", "", "30013", "611",
+		}},
+		{Line: 2, StUSAB: "in", LogRecNo: "0000002", Values: []string{
+			"ACSSF", "2013e5", "in", "000", "0057", "0000002",
+			"51000", "600", "52000", "700", "31000", "800",
+		}},
+	}
+	for _, want := range wantRows {
+		row, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(row, want) {
+			t.Errorf("got %+v, want %+v", row, want)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("got %v after the last row, want io.EOF", err)
+	}
+
+	if _, err := NewTableReader(table, sources[:1]); err == nil {
+		t.Errorf("read a two-part table from one source")
+	}
+}
+
+func TestTableReaderErrors(t *testing.T) {
+	table := &Table{
+		Name:  "b19013",
+		Parts: []TablePart{{Sequence: 57, StartPosition: 7, CellCount: 1}},
+		Lines: []Line{{1, "Median household income"}},
+	}
+	tests := []struct {
+		name      string
+		estimates string
+		moes      string
+		err       string
+		line      int
+	}{
+		{
+			name:      "LOGRECNO mismatch",
+			estimates: "ACSSF,2013e5,in,000,0057,0000001,47529\n",
+			moes:      "ACSSF,2013m5,in,000,0057,0000002,235\n",
+			err: "b19013: line 1: LOGRECNO 0000001, 0000002 (margin of " +
+				"error) and 0000001 (sequence 57) do not match",
+		},
+		{
+			name:      "margin of error file ends early",
+			estimates: "ACSSF,2013e5,in,000,0057,0000001,47529\n",
+			moes:      "",
+			line:      1,
+		},
+		{
+			name:      "short row",
+			estimates: "ACSSF,2013e5,in,000,0057,0000001\n",
+			moes:      "ACSSF,2013m5,in,000,0057,0000001\n",
+			line:      1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewTableReader(table, []Source{{
+				Estimates: strings.NewReader(test.estimates),
+				MOEs:      strings.NewReader(test.moes),
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = reader.Next()
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if len(test.err) != 0 {
+				if err.Error() != test.err {
+					t.Errorf("got %s, want %s", err, test.err)
+				}
+				return
+			}
+			var lineError *LineError
+			if !errors.As(err, &lineError) {
+				t.Fatalf("got %v, want a *LineError", err)
+			}
+			if lineError.Line != test.line {
+				t.Errorf("error on line %d, want %d", lineError.Line, test.line)
+			}
+		})
+	}
+}
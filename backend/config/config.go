@@ -0,0 +1,267 @@
+// Package config reads the database, pool, listen, dataset and logging
+// settings shared by load_census_data, export_census_data and
+// serve_census_data.  Settings come from defaults, then an optional JSON
+// config file, then MAPBLUE_* environment variables, then command line flags,
+// each overriding the last.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const DefaultDSN = "host=/var/run/postgresql dbname=census user=census " +
+	"sslmode=disable"
+const DefaultMaxOpenConns = 95
+const DefaultMaxIdleConns = 2
+const DefaultListenAddress = "0.0.0.0:8080"
+const DefaultLogLevel = "info"
+const ConfigFileEnv = "MAPBLUE_CONFIG"
+
+var LogLevels = []string{"debug", "info"}
+
+// Datasets are PostgreSQL schemas; their names are kept to unquoted
+// lower-case identifiers so they read the same in SQL and in URLs.
+var DatasetRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// The loader builds a dataset's tables in <dataset>_staging, then swaps them
+// in, keeping the tables they replace in <dataset>_previous.
+const StagingSuffix = "_staging"
+const PreviousSuffix = "_previous"
+
+type Config struct {
+	DSN           string `json:"dsn"`
+	MaxOpenConns  int    `json:"max_open_conns"`
+	MaxIdleConns  int    `json:"max_idle_conns"`
+	ListenAddress string `json:"listen_address"`
+	Dataset       string `json:"dataset"`
+	LogLevel      string `json:"log_level"`
+
+	flags      *flag.FlagSet
+	configFile string
+	flagValues map[string]*string
+}
+
+type setting struct {
+	Name   string
+	Env    string
+	Usage  string
+	Server bool
+	Get    func(*Config) string
+	Set    func(*Config, string) error
+}
+
+var settings = []setting{
+	{
+		Name:  "dsn",
+		Env:   "MAPBLUE_DSN",
+		Usage: "PostgreSQL connection string",
+		Get:   func(c *Config) string { return c.DSN },
+		Set: func(c *Config, value string) error {
+			c.DSN = value
+			return nil
+		},
+	},
+	{
+		Name:  "max-open-conns",
+		Env:   "MAPBLUE_MAX_OPEN_CONNS",
+		Usage: "Maximum number of open database connections",
+		Get:   func(c *Config) string { return strconv.Itoa(c.MaxOpenConns) },
+		Set: func(c *Config, value string) error {
+			return setInt(&c.MaxOpenConns, value)
+		},
+	},
+	{
+		Name:  "max-idle-conns",
+		Env:   "MAPBLUE_MAX_IDLE_CONNS",
+		Usage: "Maximum number of idle database connections",
+		Get:   func(c *Config) string { return strconv.Itoa(c.MaxIdleConns) },
+		Set: func(c *Config, value string) error {
+			return setInt(&c.MaxIdleConns, value)
+		},
+	},
+	{
+		Name:   "listen",
+		Env:    "MAPBLUE_LISTEN_ADDRESS",
+		Usage:  "Address and port to listen on",
+		Server: true,
+		Get:    func(c *Config) string { return c.ListenAddress },
+		Set: func(c *Config, value string) error {
+			c.ListenAddress = value
+			return nil
+		},
+	},
+	{
+		Name:   "dataset",
+		Env:    "MAPBLUE_DATASET",
+		Usage:  "Schema of the dataset lookups use when none is requested",
+		Server: true,
+		Get:    func(c *Config) string { return c.Dataset },
+		Set: func(c *Config, value string) error {
+			c.Dataset = value
+			return nil
+		},
+	},
+	{
+		Name:  "log-level",
+		Env:   "MAPBLUE_LOG_LEVEL",
+		Usage: "Log level: " + strings.Join(LogLevels, " or "),
+		Get:   func(c *Config) string { return c.LogLevel },
+		Set: func(c *Config, value string) error {
+			c.LogLevel = value
+			return nil
+		},
+	},
+}
+
+func setInt(target *int, value string) error {
+	num, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", value)
+	}
+	*target = num
+
+	return nil
+}
+
+func Defaults() *Config {
+	return &Config{
+		DSN:           DefaultDSN,
+		MaxOpenConns:  DefaultMaxOpenConns,
+		MaxIdleConns:  DefaultMaxIdleConns,
+		ListenAddress: DefaultListenAddress,
+		LogLevel:      DefaultLogLevel,
+	}
+}
+
+// Registers the settings' flags (and -config) on a flag set, returning a
+// config holding the defaults.  Call Load after parsing the flags.  Only
+// the server registers the listen address and default dataset.
+func RegisterFlags(flags *flag.FlagSet, server bool) *Config {
+	c := Defaults()
+	c.flags = flags
+	c.flagValues = make(map[string]*string)
+
+	flags.StringVar(&c.configFile, "config", "",
+		fmt.Sprintf("JSON config file (or set %s)", ConfigFileEnv),
+	)
+	for _, s := range settings {
+		if s.Server && !server {
+			continue
+		}
+		c.flagValues[s.Name] = flags.String(s.Name, s.Get(c),
+			fmt.Sprintf("%s (or set %s)", s.Usage, s.Env),
+		)
+	}
+
+	return c
+}
+
+// Applies the config file, environment variables and any flags given on the
+// command line, in that order, then checks the result.
+func (c *Config) Load() error {
+	configFile := c.configFile
+	if len(configFile) == 0 {
+		configFile = os.Getenv(ConfigFileEnv)
+	}
+	if len(configFile) > 0 {
+		if err := c.readFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.Env); ok {
+			if err := s.Set(c, value); err != nil {
+				return fmt.Errorf("%s: %s", s.Env, err)
+			}
+		}
+	}
+
+	if c.flags != nil {
+		var err error
+		c.flags.Visit(func(f *flag.Flag) {
+			for _, s := range settings {
+				if s.Name != f.Name || err != nil {
+					continue
+				}
+				if setErr := s.Set(c, *c.flagValues[s.Name]); setErr != nil {
+					err = fmt.Errorf("-%s: %s", s.Name, setErr)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.check()
+}
+
+func (c *Config) readFile(configFile string) error {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return fmt.Errorf("error opening config file %s (%s)", configFile, err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("error reading config file %s (%s)", configFile, err)
+	}
+
+	return nil
+}
+
+func (c *Config) check() error {
+	if len(c.DSN) == 0 {
+		return fmt.Errorf("no database connection string given")
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 {
+		return fmt.Errorf("connection pool limits must not be negative")
+	}
+	if len(c.ListenAddress) == 0 {
+		return fmt.Errorf("no listen address given")
+	}
+	if len(c.Dataset) > 0 {
+		if err := CheckDataset(c.Dataset); err != nil {
+			return err
+		}
+	}
+	for _, logLevel := range LogLevels {
+		if c.LogLevel == logLevel {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown log level %s", c.LogLevel)
+}
+
+// Returns an error if name can't be used as a dataset's schema name.
+func CheckDataset(name string) error {
+	if !DatasetRegexp.MatchString(name) {
+		return fmt.Errorf("invalid dataset name %q (use lower-case letters, "+
+			"digits and underscores)", name,
+		)
+	}
+
+	return nil
+}
+
+func (c *Config) Debug() bool {
+	return c.LogLevel == "debug"
+}
+
+// Logs only at the debug log level.
+func (c *Config) Debugf(format string, args ...interface{}) {
+	if c.Debug() {
+		log.Printf(format, args...)
+	}
+}
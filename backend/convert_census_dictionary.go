@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/camgunz/mapblue/backend/dictionary"
+	"log"
+	"os"
+)
+
+func printUsage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s\n\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] sf1.xml output.json\n\n",
+		os.Args[0],
+	)
+	fmt.Fprintln(os.Stderr,
+		"(converts a census API variables file to a dictionary for "+
+			"load_census_data to bundle, e.g. "+
+			"dictionary/dictionaries/sf1_2010.json)",
+	)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Options:")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func main() {
+	product := flag.String("product", "sf1", "Census product")
+	vintage := flag.Int("vintage", 2010, "Census vintage")
+	flag.Usage = func() { printUsage("") }
+	flag.Parse()
+	if flag.NArg() != 2 {
+		printUsage("Give a variables file and an output file")
+	}
+	variablesPath := flag.Arg(0)
+	outputPath := flag.Arg(1)
+
+	// The bundled dictionary's concept overrides are kept
+	censusDictionary, err := dictionary.Load(*product, *vintage)
+	if err != nil {
+		log.Fatalf("Error reading bundled dictionary (%s)\n", err)
+	}
+
+	variablesFile, err := os.Open(variablesPath)
+	if err != nil {
+		log.Fatalf("Error opening variables file %s (%s)\n",
+			variablesPath, err,
+		)
+	}
+	defer variablesFile.Close()
+	if err := censusDictionary.ReadVariables(variablesFile); err != nil {
+		log.Fatalf("Error parsing variables file %s (%s)\n",
+			variablesPath, err,
+		)
+	}
+
+	concepts, err := censusDictionary.APIConcepts()
+	if err != nil {
+		log.Fatalf("Error in variables file %s (%s)\n", variablesPath, err)
+	}
+	if len(concepts) == 0 {
+		log.Fatalf("Variables file %s has no concepts\n", variablesPath)
+	}
+
+	data, err := json.MarshalIndent(censusDictionary, "", "    ")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("Error writing %s (%s)\n", outputPath, err)
+	}
+	log.Printf("Wrote %s dictionary with %d tables to %s\n",
+		censusDictionary, len(concepts), outputPath,
+	)
+}
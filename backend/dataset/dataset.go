@@ -0,0 +1,122 @@
+// Package dataset holds what load_census_data and export_census_data share
+// about the dataset they work on: connecting to the database it lives in,
+// finding its schema, and picking its tables with -tables and
+// -exclude-tables.
+package dataset
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/camgunz/mapblue/backend/config"
+	_ "github.com/lib/pq"
+	"path"
+	"strings"
+)
+
+// The census tables picked by name or pattern, e.g. "p11" or "pct*".  With
+// no includes every table is picked, less the excludes.
+type TableSelection struct {
+	Includes []string
+	Excludes []string
+}
+
+// Opens the configured database with the configured pool limits.
+func Open(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+
+	return db, nil
+}
+
+// Returns the first schema on the connection's search path.
+func CurrentSchema(db *sql.DB) (string, error) {
+	var schema sql.NullString
+
+	err := db.QueryRow("SELECT current_schema()").Scan(&schema)
+	if err != nil {
+		return "", fmt.Errorf("error looking up the current schema (%s)",
+			err,
+		)
+	}
+	if !schema.Valid {
+		return "", errors.New(
+			"no schema on the search path, give one with -schema",
+		)
+	}
+
+	return schema.String, nil
+}
+
+// Parses comma-separated lists of table names and patterns to include and
+// exclude.
+func ParseTableSelection(includes string, excludes string) (*TableSelection, error) {
+	includePatterns, err := parseTablePatterns(includes)
+	if err != nil {
+		return nil, err
+	}
+	excludePatterns, err := parseTablePatterns(excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TableSelection{
+		Includes: includePatterns,
+		Excludes: excludePatterns,
+	}, nil
+}
+
+func parseTablePatterns(patterns string) ([]string, error) {
+	parsedPatterns := []string{}
+
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if len(pattern) == 0 {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %s", pattern)
+		}
+		parsedPatterns = append(parsedPatterns, pattern)
+	}
+
+	return parsedPatterns, nil
+}
+
+// Returns true if the table is included and not excluded.
+func (selection *TableSelection) Selects(tableName string) bool {
+	if len(selection.Includes) > 0 && !matches(tableName, selection.Includes) {
+		return false
+	}
+
+	return !matches(tableName, selection.Excludes)
+}
+
+func matches(tableName string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, tableName); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the tables a load manifest records, in order.  A staged load works
+// on these alone, as resuming after a swap leaves tables that are already
+// live out of the staging schema.
+func ManifestTables(tableNames []string, manifest map[string]string) []string {
+	manifestTables := []string{}
+
+	for _, tableName := range tableNames {
+		if _, ok := manifest[tableName]; ok {
+			manifestTables = append(manifestTables, tableName)
+		}
+	}
+
+	return manifestTables
+}
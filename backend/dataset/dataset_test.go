@@ -0,0 +1,83 @@
+package dataset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableSelection(t *testing.T) {
+	tests := []struct {
+		includes string
+		excludes string
+		selected []string
+		skipped  []string
+	}{
+		{"", "", []string{"p1", "pct12a"}, nil},
+		{"p11, PCT*", "", []string{"p11", "pct12a"}, []string{"p1", "h1"}},
+		{"p*", "pct*", []string{"p1", "p11"}, []string{"pct12a", "h1"}},
+		{"", "h*", []string{"p1"}, []string{"h1", "hct4"}},
+	}
+
+	for _, test := range tests {
+		selection, err := ParseTableSelection(test.includes, test.excludes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tableName := range test.selected {
+			if !selection.Selects(tableName) {
+				t.Errorf("%q less %q skips %s",
+					test.includes, test.excludes, tableName,
+				)
+			}
+		}
+		for _, tableName := range test.skipped {
+			if selection.Selects(tableName) {
+				t.Errorf("%q less %q selects %s",
+					test.includes, test.excludes, tableName,
+				)
+			}
+		}
+	}
+
+	if _, err := ParseTableSelection("p[1", ""); err == nil {
+		t.Errorf("parsed the invalid pattern p[1")
+	}
+}
+
+func TestManifestTables(t *testing.T) {
+	tableNames := []string{"geo_locations", "p1", "p11", "h1"}
+	tests := []struct {
+		name     string
+		manifest map[string]string
+		want     []string
+	}{
+		{
+			name: "everything staged",
+			manifest: map[string]string{
+				"geo_locations": "", "p1": "", "p11": "", "h1": "",
+				"census_tables": "",
+			},
+			want: tableNames,
+		},
+		{
+			// geo_locations and p1 were swapped in by the run that failed
+			name:     "resuming after a swap",
+			manifest: map[string]string{"p11": "", "h1": ""},
+			want:     []string{"p11", "h1"},
+		},
+		{
+			name:     "everything already live",
+			manifest: map[string]string{},
+			want:     []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ManifestTables(tableNames, test.manifest)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Package dictionary reads census variable dictionaries: the description of
+// each table and the name and label of each of its variables.  Dictionaries
+// are bundled in dictionaries/, converted from the census API variables file
+// (sf1.xml) by convert_census_dictionary.go, and a variables file can be read
+// in place of a bundled dictionary's concepts.
+package dictionary
+
+import (
+	"code.google.com/p/go-charset/charset"
+	_ "code.google.com/p/go-charset/data"
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//go:embed dictionaries/*.json
+var dictionaries embed.FS
+
+var conceptRegexp = regexp.MustCompile(`(.*)\.(.*)\[(\d\d*)\]`)
+var tableNameRegexp = regexp.MustCompile(`^([a-z]+)(\d+)([a-z]*)$`)
+
+// A product's dictionary.  Concepts are as the census API variables file
+// names them, e.g. "P1. TOTAL POPULATION [1]"; ConceptOverrides describe the
+// ones that don't follow that format.
+type Dictionary struct {
+	Product          string            `json:"product"`
+	Vintage          int               `json:"vintage"`
+	ConceptOverrides []ConceptOverride `json:"concept_overrides"`
+	Concepts         []SourceConcept   `json:"concepts"`
+}
+
+// Describes a concept whose name in the census API variables file doesn't
+// follow the "NAME. DESCRIPTION [COUNT]" format.
+type ConceptOverride struct {
+	Prefix        string `json:"prefix"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	VariableCount int    `json:"variable_count"`
+}
+
+// A concept and its variables as the census API variables file has them.
+type SourceConcept struct {
+	Name      string           `json:"name" xml:"name,attr"`
+	Variables []SourceVariable `json:"variables" xml:"variable"`
+}
+
+type SourceVariable struct {
+	Name        string `json:"name" xml:"name,attr"`
+	Description string `json:"description" xml:",chardata"`
+}
+
+type variablesFile struct {
+	XMLName  xml.Name        `xml:"apivariables"`
+	Concepts []SourceConcept `xml:"concept"`
+}
+
+// A table's description and variables, with lower case names as loaded.
+type Concept struct {
+	Name          string
+	Description   string
+	VariableCount int
+	Variables     []Variable
+}
+
+type Variable struct {
+	Name        string
+	Description string
+}
+
+func (dictionary *Dictionary) String() string {
+	return fmt.Sprintf("%s %d", dictionary.Product, dictionary.Vintage)
+}
+
+// Reads the bundled dictionary for a product and vintage.
+func Load(product string, vintage int) (*Dictionary, error) {
+	data, err := dictionaries.ReadFile(
+		path.Join("dictionaries", fmt.Sprintf("%s_%d.json", product, vintage)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("no %s %d dictionary (%s)",
+			product, vintage, err,
+		)
+	}
+
+	dictionary := new(Dictionary)
+	if err := json.Unmarshal(data, dictionary); err != nil {
+		return nil, fmt.Errorf("error parsing %s %d dictionary (%s)",
+			product, vintage, err,
+		)
+	}
+
+	return dictionary, nil
+}
+
+// Replaces the dictionary's concepts with those of a census API variables
+// file.
+func (dictionary *Dictionary) ReadVariables(reader io.Reader) error {
+	variables := new(variablesFile)
+
+	decoder := xml.NewDecoder(reader)
+	decoder.CharsetReader = charset.NewReader
+	if err := decoder.Decode(variables); err != nil {
+		return err
+	}
+	dictionary.Concepts = variables.Concepts
+
+	return nil
+}
+
+// Returns the dictionary's concepts by table name.  Concept names that don't
+// follow the "NAME. DESCRIPTION [COUNT]" format need an override, and each
+// concept must have as many variables as its name says.
+func (dictionary *Dictionary) APIConcepts() (map[string]Concept, error) {
+	concepts := make(map[string]Concept)
+
+	for _, sourceConcept := range dictionary.Concepts {
+		var concept Concept
+
+		match := conceptRegexp.FindStringSubmatch(sourceConcept.Name)
+		if len(match) == 0 {
+			override, ok := dictionary.findConceptOverride(sourceConcept.Name)
+			if !ok {
+				return nil, fmt.Errorf(
+					"concept %s does not match regex", sourceConcept.Name,
+				)
+			}
+			concept.Name = override.Name
+			concept.Description = override.Description
+			concept.VariableCount = override.VariableCount
+		} else {
+			concept.Name = strings.ToLower(strings.TrimSpace(match[1]))
+			concept.Description = strings.TrimSpace(match[2])
+			num, err := strconv.ParseInt(match[3], 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			concept.VariableCount = int(num)
+		}
+
+		for _, sourceVariable := range sourceConcept.Variables {
+			concept.Variables = append(concept.Variables, Variable{
+				Name:        strings.ToLower(sourceVariable.Name),
+				Description: strings.TrimSpace(sourceVariable.Description),
+			})
+		}
+		if len(concept.Variables) != concept.VariableCount {
+			return nil, fmt.Errorf(
+				"mismatched variable count for %s: %d != %d",
+				concept.Name,
+				concept.VariableCount,
+				len(concept.Variables),
+			)
+		}
+		concepts[concept.Name] = concept
+	}
+
+	return concepts, nil
+}
+
+// Builds a concept from a table's name and column count, following the
+// census' variable naming scheme: "p11" column 6 is "p0110006", "pct12a"
+// column 1 is "pct012a001".
+func GenerateConcept(tableName string, variableCount int) (Concept, error) {
+	match := tableNameRegexp.FindStringSubmatch(tableName)
+	if len(match) == 0 {
+		return Concept{}, fmt.Errorf(
+			"cannot generate variable names for table %s", tableName,
+		)
+	}
+	tableNumber, err := strconv.ParseInt(match[2], 10, 32)
+	if err != nil {
+		return Concept{}, err
+	}
+	cellDigits := 4 - len(match[3])
+	if cellDigits < 1 || len(strconv.Itoa(variableCount)) > cellDigits {
+		return Concept{}, fmt.Errorf(
+			"cannot generate %d variable names for table %s",
+			variableCount, tableName,
+		)
+	}
+
+	concept := Concept{Name: tableName, VariableCount: variableCount}
+	for vi := 1; vi <= variableCount; vi++ {
+		concept.Variables = append(concept.Variables, Variable{
+			Name: fmt.Sprintf("%s%03d%s%0*d",
+				match[1], tableNumber, match[3], cellDigits, vi,
+			),
+		})
+	}
+
+	return concept, nil
+}
+
+func (dictionary *Dictionary) findConceptOverride(conceptName string) (ConceptOverride, bool) {
+	for _, override := range dictionary.ConceptOverrides {
+		if strings.HasPrefix(conceptName, override.Prefix) {
+			return override, true
+		}
+	}
+
+	return ConceptOverride{}, false
+}
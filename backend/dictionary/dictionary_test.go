@@ -0,0 +1,186 @@
+package dictionary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateConcept(t *testing.T) {
+	tests := []struct {
+		tableName     string
+		variableCount int
+		want          []string
+		err           bool
+	}{
+		{"p1", 1, []string{"p0010001"}, false},
+		{"p11", 3, []string{"p0110001", "p0110002", "p0110003"}, false},
+		{"h22", 2, []string{"h0220001", "h0220002"}, false},
+		{"pct12a", 2, []string{"pct012a001", "pct012a002"}, false},
+		{"pct12a", 209, nil, false},
+		{"pct12a", 1000, nil, true},
+		{"p11", 10000, nil, true},
+		{"geo_locations", 1, nil, true},
+		{"P11", 1, nil, true},
+	}
+
+	for _, test := range tests {
+		concept, err := GenerateConcept(test.tableName, test.variableCount)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s with %d variables: got no error",
+					test.tableName, test.variableCount,
+				)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.tableName, err)
+			continue
+		}
+		if concept.Name != test.tableName ||
+			concept.VariableCount != test.variableCount ||
+			len(concept.Variables) != test.variableCount {
+			t.Errorf("%s: got %s with %d of %d variables", test.tableName,
+				concept.Name, len(concept.Variables), concept.VariableCount,
+			)
+			continue
+		}
+		for vi, name := range test.want {
+			if concept.Variables[vi].Name != name {
+				t.Errorf("%s variable %d = %s, want %s",
+					test.tableName, vi+1, concept.Variables[vi].Name, name,
+				)
+			}
+		}
+	}
+}
+
+const variables = `<?xml version="1.0" encoding="UTF-8"?>
+<apivariables>
+  <concept name="Geographic Characteristics">
+    %s
+  </concept>
+  <concept name="P1.  TOTAL POPULATION [1]">
+    <variable name="P0010001">Total population</variable>
+  </concept>
+  <concept name="P4.  HISPANIC OR LATINO ORIGIN [3]">
+    <variable name="P0040001">Total:</variable>
+    <variable name="P0040002">Not Hispanic or Latino</variable>
+    <variable name="P0040003">Hispanic or Latino</variable>
+  </concept>
+</apivariables>
+`
+
+func geoVariables(count int) string {
+	lines := []string{}
+	for vi := 0; vi < count; vi++ {
+		lines = append(lines, `<variable name="GEOVAR">Geography</variable>`)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func TestAPIConcepts(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables string
+		err       string
+	}{
+		{
+			name:      "bundled overrides",
+			variables: strings.Replace(variables, "%s", geoVariables(33), 1),
+		},
+		{
+			name:      "override count",
+			variables: strings.Replace(variables, "%s", geoVariables(32), 1),
+			err:       "mismatched variable count for geo_locations: 33 != 32",
+		},
+		{
+			name: "concept count",
+			variables: strings.Replace(
+				strings.Replace(variables, "%s", geoVariables(33), 1),
+				"[3]", "[4]", 1,
+			),
+			err: "mismatched variable count for p4: 4 != 3",
+		},
+		{
+			name: "unknown concept format",
+			variables: strings.Replace(
+				strings.Replace(variables, "%s", geoVariables(33), 1),
+				"P1.  TOTAL POPULATION [1]", "P1 TOTAL POPULATION", 1,
+			),
+			err: "concept P1 TOTAL POPULATION does not match regex",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dictionary, err := Load("sf1", 2010)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = dictionary.ReadVariables(strings.NewReader(test.variables))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			concepts, err := dictionary.APIConcepts()
+			if len(test.err) != 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(concepts) != 3 {
+				t.Errorf("got %d concepts, want 3", len(concepts))
+			}
+			if concepts["geo_locations"].Description !=
+				"Geographic Characteristics" {
+				t.Errorf("geo_locations = %+v", concepts["geo_locations"])
+			}
+			want := Concept{
+				Name:          "p4",
+				Description:   "HISPANIC OR LATINO ORIGIN",
+				VariableCount: 3,
+				Variables: []Variable{
+					{"p0040001", "Total:"},
+					{"p0040002", "Not Hispanic or Latino"},
+					{"p0040003", "Hispanic or Latino"},
+				},
+			}
+			if !reflect.DeepEqual(concepts["p4"], want) {
+				t.Errorf("p4 = %+v, want %+v", concepts["p4"], want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dictionary, err := Load("sf1", 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dictionary.String() != "sf1 2010" {
+		t.Errorf("loaded the %s dictionary", dictionary)
+	}
+	if _, err := dictionary.APIConcepts(); err != nil {
+		t.Errorf("bundled dictionary: %s", err)
+	}
+	for _, name := range []string{"geo_locations", "pct22a", "pct22d"} {
+		found := false
+		for _, override := range dictionary.ConceptOverrides {
+			found = found || override.Name == name
+		}
+		if !found {
+			t.Errorf("bundled dictionary has no %s override", name)
+		}
+	}
+
+	if _, err := Load("sf1", 1990); err == nil {
+		t.Errorf("loaded a 1990 dictionary")
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/camgunz/mapblue/backend/config"
+	"github.com/camgunz/mapblue/backend/dataset"
+	"github.com/camgunz/mapblue/backend/gpkg"
+	"github.com/lib/pq"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// A column of a table being exported.
+type ExportColumn struct {
+	Name     string
+	Type     string
+	Geometry bool
+}
+
+var DB *sql.DB
+var CONFIG *config.Config
+var SCHEMA = ""
+var TABLE_SELECTION = &dataset.TableSelection{}
+
+// Tables exported whenever the dataset has them, whatever -tables says.
+var DATASET_TABLES = []string{
+	"geo_locations", "tabblock", "block_demographics", "census_tables",
+	"census_variables",
+}
+
+var DATASET_TABLE_DESCRIPTIONS = map[string]string{
+	"geo_locations":      "Geographic header records",
+	"tabblock":           "TIGER/Line census block geometries",
+	"block_demographics": "Census block demographics",
+	"census_tables":      "Census tables in this dataset",
+	"census_variables":   "Census table variables",
+}
+
+func printUsage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s\n\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] output.gpkg\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr,
+		"(writes a dataset loaded by load_census_data to a GeoPackage, "+
+			"which QGIS and any SQLite client can open)",
+	)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Options:")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func isDatasetTable(tableName string) bool {
+	for _, datasetTable := range DATASET_TABLES {
+		if tableName == datasetTable {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tableIdentifier(tableName string) string {
+	return pq.QuoteIdentifier(SCHEMA) + "." + pq.QuoteIdentifier(tableName)
+}
+
+func tableExists(tableName string) (bool, error) {
+	var exists bool
+
+	err := DB.QueryRow(
+		"SELECT to_regclass($1) IS NOT NULL", tableIdentifier(tableName),
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// Returns the tables to export: the dataset's own tables, then the census
+// tables the load manifest lists that were selected.  block_demographics
+// isn't in the manifest, as it's built after the load.
+func selectTables() ([]string, error) {
+	rows, err := DB.Query(
+		"SELECT table_name FROM " + tableIdentifier("load_manifest") +
+			" ORDER BY table_name",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading load manifest (%s)", err)
+	}
+	defer rows.Close()
+
+	loaded := make(map[string]bool)
+	censusTables := []string{}
+	for rows.Next() {
+		var tableName string
+
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		loaded[tableName] = true
+		if !isDatasetTable(tableName) && TABLE_SELECTION.Selects(tableName) {
+			censusTables = append(censusTables, tableName)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tableNames := []string{}
+	for _, tableName := range DATASET_TABLES {
+		exists := loaded[tableName]
+		if !exists {
+			exists, err = tableExists(tableName)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if exists {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+
+	return append(tableNames, censusTables...), nil
+}
+
+// Reads census table descriptions from census_tables, if the dataset has it.
+func readTableDescriptions() (map[string]string, error) {
+	descriptions := make(map[string]string)
+	for tableName, description := range DATASET_TABLE_DESCRIPTIONS {
+		descriptions[tableName] = description
+	}
+
+	exists, err := tableExists("census_tables")
+	if err != nil || !exists {
+		return descriptions, err
+	}
+
+	rows, err := DB.Query(
+		"SELECT table_name, description FROM " +
+			tableIdentifier("census_tables"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName string
+		var description sql.NullString
+
+		if err := rows.Scan(&tableName, &description); err != nil {
+			return nil, err
+		}
+		descriptions[tableName] = description.String
+	}
+
+	return descriptions, rows.Err()
+}
+
+// Reads a table's columns, typing each as SQLite stores it.  Geometry
+// columns are typed with their geometry type, e.g. MULTIPOLYGON.
+func readColumns(tableName string) ([]ExportColumn, error) {
+	rows, err := DB.Query(
+		"SELECT column_name, data_type, udt_name "+
+			"FROM information_schema.columns "+
+			"WHERE table_schema = $1 AND table_name = $2 "+
+			"ORDER BY ordinal_position",
+		SCHEMA, tableName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []ExportColumn{}
+	for rows.Next() {
+		var column ExportColumn
+		var dataType, udtName string
+
+		if err := rows.Scan(&column.Name, &dataType, &udtName); err != nil {
+			return nil, err
+		}
+		switch dataType {
+		case "smallint", "integer", "bigint":
+			column.Type = gpkg.TypeInteger
+		case "numeric", "real", "double precision":
+			column.Type = gpkg.TypeReal
+		default:
+			column.Type = gpkg.TypeText
+		}
+		column.Geometry = udtName == "geometry"
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// Reads a geometry column's type and SRID from PostGIS.
+func readGeometryColumn(tableName string, columnName string) (string, int, error) {
+	var geometryType string
+	var srid int
+
+	err := DB.QueryRow(
+		"SELECT type, srid FROM geometry_columns "+
+			"WHERE f_table_schema = $1 AND f_table_name = $2 "+
+			"AND f_geometry_column = $3",
+		SCHEMA, tableName, columnName,
+	).Scan(&geometryType, &srid)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading geometry column %s.%s (%s)",
+			tableName, columnName, err,
+		)
+	}
+
+	return strings.ToUpper(geometryType), srid, nil
+}
+
+// Adds a PostGIS SRID's definition to the GeoPackage.
+func addSRS(output *gpkg.File, srid int) error {
+	for _, srs := range gpkg.RequiredSRSs {
+		if srs.ID == srid {
+			return nil
+		}
+	}
+
+	var authName sql.NullString
+	var authSRID sql.NullInt64
+	var srText sql.NullString
+	err := DB.QueryRow(
+		"SELECT auth_name, auth_srid, srtext FROM spatial_ref_sys "+
+			"WHERE srid = $1",
+		srid,
+	).Scan(&authName, &authSRID, &srText)
+	if err != nil {
+		return fmt.Errorf("error reading SRID %d (%s)", srid, err)
+	}
+
+	return output.AddSRS(gpkg.SRS{
+		Name:           fmt.Sprintf("%s:%d", authName.String, authSRID.Int64),
+		ID:             srid,
+		Organization:   authName.String,
+		OrganizationID: int(authSRID.Int64),
+		Definition:     srText.String,
+	})
+}
+
+// Copies a table into the GeoPackage, under the same name and with the same
+// columns.  Tables with an integer id keep it as their primary key; others
+// get a fid.  Returns the number of rows written.
+func exportTable(output *gpkg.File, tableName string, description string) (int, error) {
+	columns, err := readColumns(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	table := &gpkg.Table{Name: tableName, Description: description}
+	selectExpressions := []string{}
+	orderBy := ""
+	for _, column := range columns {
+		identifier := pq.QuoteIdentifier(column.Name)
+		if column.Geometry {
+			if len(table.GeometryColumn) > 0 {
+				return 0, fmt.Errorf("table %s has more than one "+
+					"geometry column", tableName,
+				)
+			}
+			geometryType, srid, err := readGeometryColumn(
+				tableName, column.Name,
+			)
+			if err != nil {
+				return 0, err
+			}
+			if err := addSRS(output, srid); err != nil {
+				return 0, err
+			}
+			column.Type = geometryType
+			table.GeometryColumn = column.Name
+			table.GeometryType = geometryType
+			table.SRSID = srid
+			selectExpressions = append(selectExpressions, fmt.Sprintf(
+				"ST_AsBinary(%[1]s, 'NDR'), ST_IsEmpty(%[1]s), "+
+					"ST_XMin(%[1]s), ST_XMax(%[1]s), "+
+					"ST_YMin(%[1]s), ST_YMax(%[1]s)", identifier,
+			))
+		} else if column.Type == gpkg.TypeText {
+			selectExpressions = append(selectExpressions, identifier+"::text")
+		} else if column.Type == gpkg.TypeReal {
+			selectExpressions = append(selectExpressions,
+				identifier+"::double precision",
+			)
+		} else {
+			selectExpressions = append(selectExpressions, identifier)
+		}
+		if column.Name == "id" && column.Type == gpkg.TypeInteger {
+			table.PrimaryKey = column.Name
+			orderBy = " ORDER BY " + identifier
+		}
+		table.Columns = append(table.Columns, gpkg.Column{
+			Name: column.Name,
+			Type: column.Type,
+		})
+	}
+
+	tableWriter, err := output.CreateTable(table)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeTableRows(tableWriter, columns, selectExpressions, orderBy); err != nil {
+		tableWriter.Abort()
+		return 0, err
+	}
+
+	return tableWriter.RowCount, tableWriter.Close()
+}
+
+func writeTableRows(tableWriter *gpkg.TableWriter, columns []ExportColumn, selectExpressions []string, orderBy string) error {
+	rows, err := DB.Query(
+		"SELECT " + strings.Join(selectExpressions, ", ") + " FROM " +
+			tableIdentifier(tableWriter.Table.Name) + orderBy,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// A geometry is read as its WKB, whether it's empty, and its bounds
+	scanCount := len(columns)
+	for _, column := range columns {
+		if column.Geometry {
+			scanCount += 5
+		}
+	}
+	scanned := make([]interface{}, scanCount)
+	scanTargets := make([]interface{}, scanCount)
+	for si := range scanned {
+		scanTargets[si] = &scanned[si]
+	}
+
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(scanTargets...); err != nil {
+			return err
+		}
+		si := 0
+		for ci, column := range columns {
+			if !column.Geometry {
+				values[ci] = scanned[si]
+				si++
+				continue
+			}
+			values[ci] = scannedGeometry(scanned[si : si+6])
+			si += 6
+		}
+		if err := tableWriter.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Builds a geometry from its WKB, emptiness and bounds, or returns nil if
+// it's NULL.
+func scannedGeometry(scanned []interface{}) *gpkg.Geometry {
+	wkb, ok := scanned[0].([]byte)
+	if !ok {
+		return nil
+	}
+	geometry := &gpkg.Geometry{WKB: append([]byte{}, wkb...)}
+	if empty, ok := scanned[1].(bool); ok && empty {
+		geometry.Empty = true
+		return geometry
+	}
+	geometry.MinX, _ = scanned[2].(float64)
+	geometry.MaxX, _ = scanned[3].(float64)
+	geometry.MinY, _ = scanned[4].(float64)
+	geometry.MaxY, _ = scanned[5].(float64)
+
+	return geometry
+}
+
+func main() {
+	schema := flag.String("schema", "",
+		"PostgreSQL schema of the dataset to export; defaults to the "+
+			"connection's search path",
+	)
+	tables := flag.String("tables", "",
+		"Comma-separated census tables to export, by name or pattern "+
+			"(e.g. 'p11,p16,pct*'); defaults to all loaded tables.  "+
+			"geo_locations, tabblock, block_demographics and the census "+
+			"metadata are always exported",
+	)
+	excludeTables := flag.String("exclude-tables", "",
+		"Comma-separated census tables not to export, by name or pattern",
+	)
+	CONFIG = config.RegisterFlags(flag.CommandLine, false)
+	flag.Usage = func() { printUsage("") }
+	flag.Parse()
+	if err := CONFIG.Load(); err != nil {
+		printUsage(err.Error())
+	}
+	tableSelection, err := dataset.ParseTableSelection(*tables, *excludeTables)
+	if err != nil {
+		printUsage(err.Error())
+	}
+	TABLE_SELECTION = tableSelection
+	if flag.NArg() != 1 {
+		printUsage("Give one output file")
+	}
+	outputPath := flag.Arg(0)
+	if len(*schema) > 0 {
+		if err := config.CheckDataset(*schema); err != nil {
+			printUsage(err.Error())
+		}
+	}
+
+	DB, err = dataset.Open(CONFIG)
+	if err != nil {
+		log.Fatalf("Error connecting to database (%s)\n", err)
+	}
+	defer DB.Close()
+	SCHEMA = *schema
+	if len(SCHEMA) == 0 {
+		SCHEMA, err = dataset.CurrentSchema(DB)
+		if err != nil {
+			log.Fatalf("Error finding the schema (%s)\n", err)
+		}
+	}
+	tableNames, err := selectTables()
+	if err != nil {
+		log.Fatalf("Error finding tables in %s (%s)\n", SCHEMA, err)
+	}
+	descriptions, err := readTableDescriptions()
+	if err != nil {
+		log.Fatalf("Error reading census table metadata (%s)\n", err)
+	}
+
+	startTime := time.Now()
+	output, err := gpkg.Create(outputPath)
+	if err != nil {
+		log.Fatalf("Error creating %s (%s)\n", outputPath, err)
+	}
+	for _, tableName := range tableNames {
+		rowCount, err := exportTable(
+			output, tableName, descriptions[tableName],
+		)
+		if err != nil {
+			output.Abort()
+			log.Fatalf("Error exporting table %s (%s)\n", tableName, err)
+		}
+		log.Printf("Exported table %s (%d rows)\n", tableName, rowCount)
+	}
+	if err := output.Close(); err != nil {
+		log.Fatalf("Error writing %s (%s)\n", outputPath, err)
+	}
+
+	log.Printf("Exported %d tables from %s to %s in %s\n",
+		len(tableNames), SCHEMA, outputPath,
+		time.Since(startTime).Truncate(time.Second),
+	)
+}
@@ -0,0 +1,509 @@
+// Package gpkg writes OGC GeoPackage files: SQLite databases that QGIS and
+// other GIS tools open as layers, and that any SQLite client can query.
+// Tables with a geometry column become feature tables, with an R-tree
+// spatial index; the rest become attribute tables.
+//
+// A file is written to a temporary path and renamed into place when it's
+// closed, so an export that fails never leaves a partial file behind.
+package gpkg
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// "GPKG", and GeoPackage version 1.3.0.
+const ApplicationID = 0x47504B47
+const UserVersion = 10300
+
+const SRID_WGS84 = 4326
+
+// SQLite column types, as GeoPackage allows them.
+const TypeInteger = "INTEGER"
+const TypeReal = "REAL"
+const TypeText = "TEXT"
+
+// A spatial reference system, as in gpkg_spatial_ref_sys.
+type SRS struct {
+	Name           string
+	ID             int
+	Organization   string
+	OrganizationID int
+	Definition     string
+}
+
+// The reference systems every GeoPackage holds.
+var RequiredSRSs = []SRS{
+	{
+		Name:           "WGS 84 geodetic",
+		ID:             SRID_WGS84,
+		Organization:   "EPSG",
+		OrganizationID: SRID_WGS84,
+		Definition: `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",` +
+			`6378137,298.257223563,AUTHORITY["EPSG","7030"]],` +
+			`AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,` +
+			`AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,` +
+			`AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`,
+	},
+	{
+		Name:           "Undefined cartesian SRS",
+		ID:             -1,
+		Organization:   "NONE",
+		OrganizationID: -1,
+		Definition:     "undefined",
+	},
+	{
+		Name:           "Undefined geographic SRS",
+		ID:             0,
+		Organization:   "NONE",
+		OrganizationID: 0,
+		Definition:     "undefined",
+	},
+}
+
+type Column struct {
+	Name string
+	Type string
+}
+
+// A table to write.  PrimaryKey names its integer primary key column, which
+// must be in Columns; if it's empty, a "fid" column is added.  Tables with a
+// GeometryColumn (in Columns, typed with its GeometryType, e.g. POINT or
+// MULTIPOLYGON) are feature tables in SRSID.
+type Table struct {
+	Name           string
+	Description    string
+	Columns        []Column
+	PrimaryKey     string
+	GeometryColumn string
+	GeometryType   string
+	SRSID          int
+}
+
+// A geometry as well-known binary, with its bounding box.  Empty geometries
+// (e.g. POINT EMPTY) have no bounding box and aren't spatially indexed.
+type Geometry struct {
+	WKB   []byte
+	Empty bool
+	MinX  float64
+	MaxX  float64
+	MinY  float64
+	MaxY  float64
+}
+
+type File struct {
+	Path string
+
+	db       *sql.DB
+	tempPath string
+}
+
+// Writes a table's rows in one transaction.
+type TableWriter struct {
+	Table    *Table
+	RowCount int
+
+	file        *File
+	tx          *sql.Tx
+	insert      *sql.Stmt
+	rtreeInsert *sql.Stmt
+	extent      *Geometry
+}
+
+// Creates a GeoPackage, which replaces any file at path once it's closed.
+func Create(path string) (*File, error) {
+	f := &File{Path: path, tempPath: path + ".tmp"}
+
+	if err := os.Remove(f.tempPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", f.tempPath)
+	if err != nil {
+		return nil, err
+	}
+	f.db = db
+	// A single connection, so every statement sees the same database
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		fmt.Sprintf("PRAGMA application_id = %d", ApplicationID),
+		fmt.Sprintf("PRAGMA user_version = %d", UserVersion),
+		"CREATE TABLE gpkg_spatial_ref_sys (" +
+			"srs_name TEXT NOT NULL, " +
+			"srs_id INTEGER NOT NULL PRIMARY KEY, " +
+			"organization TEXT NOT NULL, " +
+			"organization_coordsys_id INTEGER NOT NULL, " +
+			"definition TEXT NOT NULL, " +
+			"description TEXT" +
+			")",
+		"CREATE TABLE gpkg_contents (" +
+			"table_name TEXT NOT NULL PRIMARY KEY, " +
+			"data_type TEXT NOT NULL, " +
+			"identifier TEXT UNIQUE, " +
+			"description TEXT DEFAULT '', " +
+			"last_change DATETIME NOT NULL DEFAULT " +
+			"(strftime('%Y-%m-%dT%H:%M:%fZ','now')), " +
+			"min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, " +
+			"srs_id INTEGER, " +
+			"CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) " +
+			"REFERENCES gpkg_spatial_ref_sys(srs_id)" +
+			")",
+		"CREATE TABLE gpkg_geometry_columns (" +
+			"table_name TEXT NOT NULL, " +
+			"column_name TEXT NOT NULL, " +
+			"geometry_type_name TEXT NOT NULL, " +
+			"srs_id INTEGER NOT NULL, " +
+			"z TINYINT NOT NULL, " +
+			"m TINYINT NOT NULL, " +
+			"CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name), " +
+			"CONSTRAINT uk_gc_table_name UNIQUE (table_name), " +
+			"CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) " +
+			"REFERENCES gpkg_contents(table_name), " +
+			"CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) " +
+			"REFERENCES gpkg_spatial_ref_sys (srs_id)" +
+			")",
+		"CREATE TABLE gpkg_extensions (" +
+			"table_name TEXT, " +
+			"column_name TEXT, " +
+			"extension_name TEXT NOT NULL, " +
+			"definition TEXT NOT NULL, " +
+			"scope TEXT NOT NULL, " +
+			"CONSTRAINT ge_tce UNIQUE " +
+			"(table_name, column_name, extension_name)" +
+			")",
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			f.abort()
+			return nil, fmt.Errorf("error creating %s (%s)", path, err)
+		}
+	}
+	for _, srs := range RequiredSRSs {
+		if err := f.AddSRS(srs); err != nil {
+			f.abort()
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Adds a spatial reference system, unless one with its ID is already there.
+func (f *File) AddSRS(srs SRS) error {
+	_, err := f.db.Exec(
+		"INSERT OR IGNORE INTO gpkg_spatial_ref_sys "+
+			"(srs_name, srs_id, organization, organization_coordsys_id, "+
+			"definition) VALUES (?, ?, ?, ?, ?)",
+		srs.Name, srs.ID, srs.Organization, srs.OrganizationID,
+		srs.Definition,
+	)
+	if err != nil {
+		return fmt.Errorf("error adding SRS %d (%s)", srs.ID, err)
+	}
+
+	return nil
+}
+
+// Creates a table and registers it in gpkg_contents, returning the writer its
+// rows go through.
+func (f *File) CreateTable(table *Table) (*TableWriter, error) {
+	columnDefinitions := []string{}
+	columnNames := []string{}
+	placeholders := []string{}
+	if len(table.PrimaryKey) == 0 {
+		table.PrimaryKey = "fid"
+		columnDefinitions = append(columnDefinitions,
+			quoteIdentifier("fid")+" INTEGER PRIMARY KEY AUTOINCREMENT",
+		)
+	}
+	for _, column := range table.Columns {
+		definition := quoteIdentifier(column.Name) + " " + column.Type
+		if column.Name == table.PrimaryKey {
+			definition += " PRIMARY KEY AUTOINCREMENT"
+		}
+		columnDefinitions = append(columnDefinitions, definition)
+		columnNames = append(columnNames, quoteIdentifier(column.Name))
+		placeholders = append(placeholders, "?")
+	}
+
+	tw := &TableWriter{Table: table, file: f}
+	tx, err := f.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	tw.tx = tx
+
+	dataType := "attributes"
+	var srsID interface{}
+	if len(table.GeometryColumn) > 0 {
+		dataType = "features"
+		srsID = table.SRSID
+	}
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{fmt.Sprintf("CREATE TABLE %s (%s)",
+			quoteIdentifier(table.Name), strings.Join(columnDefinitions, ", "),
+		), nil},
+		{"INSERT INTO gpkg_contents " +
+			"(table_name, data_type, identifier, description, srs_id) " +
+			"VALUES (?, ?, ?, ?, ?)",
+			[]interface{}{
+				table.Name, dataType, table.Name, table.Description, srsID,
+			},
+		},
+	}
+	if len(table.GeometryColumn) > 0 {
+		statements = append(statements, []struct {
+			query string
+			args  []interface{}
+		}{
+			{"INSERT INTO gpkg_geometry_columns " +
+				"(table_name, column_name, geometry_type_name, srs_id, z, m) " +
+				"VALUES (?, ?, ?, ?, 0, 0)",
+				[]interface{}{
+					table.Name, table.GeometryColumn, table.GeometryType,
+					table.SRSID,
+				},
+			},
+			{fmt.Sprintf(
+				"CREATE VIRTUAL TABLE %s USING rtree(id, minx, maxx, miny, maxy)",
+				quoteIdentifier(tw.rtreeName()),
+			), nil},
+			{"INSERT INTO gpkg_extensions " +
+				"(table_name, column_name, extension_name, definition, scope) " +
+				"VALUES (?, ?, 'gpkg_rtree_index', " +
+				"'http://www.geopackage.org/spec/#extension_rtree', " +
+				"'write-only')",
+				[]interface{}{table.Name, table.GeometryColumn},
+			},
+		}...)
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("error creating table %s (%s)",
+				table.Name, err,
+			)
+		}
+	}
+
+	tw.insert, err = tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(table.Name), strings.Join(columnNames, ", "),
+		strings.Join(placeholders, ", "),
+	))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(table.GeometryColumn) > 0 {
+		tw.rtreeInsert, err = tx.Prepare(fmt.Sprintf(
+			"INSERT INTO %s VALUES (?, ?, ?, ?, ?)",
+			quoteIdentifier(tw.rtreeName()),
+		))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return tw, nil
+}
+
+func (tw *TableWriter) rtreeName() string {
+	return "rtree_" + tw.Table.Name + "_" + tw.Table.GeometryColumn
+}
+
+// Writes a row, with a value for each of the table's columns.  The geometry
+// column's value is a *Geometry, or nil.
+func (tw *TableWriter) WriteRow(values []interface{}) error {
+	if len(values) != len(tw.Table.Columns) {
+		return fmt.Errorf("%s: got %d values for %d columns",
+			tw.Table.Name, len(values), len(tw.Table.Columns),
+		)
+	}
+
+	var geometry *Geometry
+	args := make([]interface{}, len(values))
+	for vi, value := range values {
+		g, ok := value.(*Geometry)
+		if !ok {
+			args[vi] = value
+			continue
+		}
+		if g == nil {
+			args[vi] = nil
+			continue
+		}
+		geometry = g
+		args[vi] = encodeGeometry(g, tw.Table.SRSID)
+	}
+
+	result, err := tw.insert.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tw.Table.Name, err)
+	}
+	tw.RowCount++
+	if geometry == nil || geometry.Empty || tw.rtreeInsert == nil {
+		return nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tw.rtreeInsert.Exec(id,
+		geometry.MinX, geometry.MaxX, geometry.MinY, geometry.MaxY,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tw.Table.Name, err)
+	}
+	tw.addToExtent(geometry)
+
+	return nil
+}
+
+func (tw *TableWriter) addToExtent(geometry *Geometry) {
+	if tw.extent == nil {
+		extent := *geometry
+		tw.extent = &extent
+		return
+	}
+	tw.extent.MinX = math.Min(tw.extent.MinX, geometry.MinX)
+	tw.extent.MaxX = math.Max(tw.extent.MaxX, geometry.MaxX)
+	tw.extent.MinY = math.Min(tw.extent.MinY, geometry.MinY)
+	tw.extent.MaxY = math.Max(tw.extent.MaxY, geometry.MaxY)
+}
+
+// Records the table's extent, adds the triggers that keep its spatial index
+// up to date when it's edited, and commits.
+func (tw *TableWriter) Close() error {
+	tw.insert.Close()
+	if tw.rtreeInsert != nil {
+		tw.rtreeInsert.Close()
+	}
+
+	statements := []string{}
+	if tw.extent != nil {
+		statements = append(statements, fmt.Sprintf(
+			"UPDATE gpkg_contents SET min_x = %v, max_x = %v, "+
+				"min_y = %v, max_y = %v WHERE table_name = '%s'",
+			tw.extent.MinX, tw.extent.MaxX, tw.extent.MinY, tw.extent.MaxY,
+			strings.ReplaceAll(tw.Table.Name, "'", "''"),
+		))
+	}
+	if tw.rtreeInsert != nil {
+		statements = append(statements, tw.rtreeTriggers()...)
+	}
+	for _, statement := range statements {
+		if _, err := tw.tx.Exec(statement); err != nil {
+			tw.tx.Rollback()
+			return fmt.Errorf("%s: %s", tw.Table.Name, err)
+		}
+	}
+
+	return tw.tx.Commit()
+}
+
+// Drops the table's rows.
+func (tw *TableWriter) Abort() {
+	tw.tx.Rollback()
+}
+
+// The R-tree extension's triggers.  They call the ST_ functions GIS tools
+// register, so they only matter to (and only run in) editors such as QGIS.
+func (tw *TableWriter) rtreeTriggers() []string {
+	replacer := strings.NewReplacer(
+		"<t>", quoteIdentifier(tw.Table.Name),
+		"<c>", quoteIdentifier(tw.Table.GeometryColumn),
+		"<i>", quoteIdentifier(tw.Table.PrimaryKey),
+		"<r>", quoteIdentifier(tw.rtreeName()),
+		"<n>", tw.rtreeName(),
+	)
+	upsert := "INSERT OR REPLACE INTO <r> VALUES (NEW.<i>, " +
+		"ST_MinX(NEW.<c>), ST_MaxX(NEW.<c>), " +
+		"ST_MinY(NEW.<c>), ST_MaxY(NEW.<c>)); "
+	triggers := []string{
+		`CREATE TRIGGER "<n>_insert" AFTER INSERT ON <t> ` +
+			"WHEN (NEW.<c> NOT NULL AND NOT ST_IsEmpty(NEW.<c>)) " +
+			"BEGIN " + upsert + "END",
+		`CREATE TRIGGER "<n>_update1" AFTER UPDATE OF <c> ON <t> ` +
+			"WHEN OLD.<i> = NEW.<i> AND " +
+			"(NEW.<c> NOTNULL AND NOT ST_IsEmpty(NEW.<c>)) " +
+			"BEGIN " + upsert + "END",
+		`CREATE TRIGGER "<n>_update2" AFTER UPDATE OF <c> ON <t> ` +
+			"WHEN OLD.<i> = NEW.<i> AND " +
+			"(NEW.<c> ISNULL OR ST_IsEmpty(NEW.<c>)) " +
+			"BEGIN DELETE FROM <r> WHERE id = OLD.<i>; END",
+		`CREATE TRIGGER "<n>_update3" AFTER UPDATE ON <t> ` +
+			"WHEN OLD.<i> != NEW.<i> AND " +
+			"(NEW.<c> NOTNULL AND NOT ST_IsEmpty(NEW.<c>)) " +
+			"BEGIN DELETE FROM <r> WHERE id = OLD.<i>; " + upsert + "END",
+		`CREATE TRIGGER "<n>_update4" AFTER UPDATE ON <t> ` +
+			"WHEN OLD.<i> != NEW.<i> AND " +
+			"(NEW.<c> ISNULL OR ST_IsEmpty(NEW.<c>)) " +
+			"BEGIN DELETE FROM <r> WHERE id IN (OLD.<i>, NEW.<i>); END",
+		`CREATE TRIGGER "<n>_delete" AFTER DELETE ON <t> ` +
+			"WHEN OLD.<c> NOT NULL " +
+			"BEGIN DELETE FROM <r> WHERE id = OLD.<i>; END",
+	}
+	for ti, trigger := range triggers {
+		triggers[ti] = replacer.Replace(trigger)
+	}
+
+	return triggers
+}
+
+// Closes the file and moves it into place.
+func (f *File) Close() error {
+	if err := f.db.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.tempPath, f.Path)
+}
+
+// Closes and removes the unfinished file.
+func (f *File) Abort() {
+	f.abort()
+}
+
+func (f *File) abort() {
+	f.db.Close()
+	os.Remove(f.tempPath)
+}
+
+// Encodes a geometry as a GeoPackage geometry blob: a little-endian header
+// with the SRS ID and the bounding box, then the WKB.
+func encodeGeometry(geometry *Geometry, srsID int) []byte {
+	blob := make([]byte, 8, 8+32+len(geometry.WKB))
+	blob[0] = 'G'
+	blob[1] = 'P'
+	blob[2] = 0 // version 1
+	binary.LittleEndian.PutUint32(blob[4:], uint32(int32(srsID)))
+	if geometry.Empty {
+		blob[3] = 0x11 // little endian, empty, no envelope
+		return append(blob, geometry.WKB...)
+	}
+
+	blob[3] = 0x03 // little endian, [minx, maxx, miny, maxy] envelope
+	blob = blob[:8+32]
+	for ei, value := range []float64{
+		geometry.MinX, geometry.MaxX, geometry.MinY, geometry.MaxY,
+	} {
+		binary.LittleEndian.PutUint64(blob[8+ei*8:], math.Float64bits(value))
+	}
+
+	return append(blob, geometry.WKB...)
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
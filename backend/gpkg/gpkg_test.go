@@ -0,0 +1,236 @@
+package gpkg
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// A point as little-endian WKB.
+func pointWKB(x float64, y float64) []byte {
+	wkb := []byte{1, 1, 0, 0, 0}
+	wkb = binary.LittleEndian.AppendUint64(wkb, math.Float64bits(x))
+	return binary.LittleEndian.AppendUint64(wkb, math.Float64bits(y))
+}
+
+func TestEncodeGeometry(t *testing.T) {
+	point := pointWKB(-86.15, 39.76)
+	emptyPoint := pointWKB(math.NaN(), math.NaN())
+	tests := []struct {
+		name     string
+		geometry *Geometry
+		srsID    int
+		header   []byte
+		envelope []float64
+		wkb      []byte
+	}{
+		{
+			name: "envelope",
+			geometry: &Geometry{
+				WKB:  point,
+				MinX: -86.15, MaxX: -86.15, MinY: 39.76, MaxY: 39.76,
+			},
+			srsID:    4269,
+			header:   []byte{'G', 'P', 0, 0x03, 0xad, 0x10, 0, 0},
+			envelope: []float64{-86.15, -86.15, 39.76, 39.76},
+			wkb:      point,
+		},
+		{
+			name:     "empty",
+			geometry: &Geometry{WKB: emptyPoint, Empty: true},
+			srsID:    4326,
+			header:   []byte{'G', 'P', 0, 0x11, 0xe6, 0x10, 0, 0},
+			wkb:      emptyPoint,
+		},
+		{
+			name:     "undefined cartesian SRS",
+			geometry: &Geometry{WKB: point, MinX: 1, MaxX: 2, MinY: 3, MaxY: 4},
+			srsID:    -1,
+			header:   []byte{'G', 'P', 0, 0x03, 0xff, 0xff, 0xff, 0xff},
+			envelope: []float64{1, 2, 3, 4},
+			wkb:      point,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blob := encodeGeometry(test.geometry, test.srsID)
+
+			if !bytes.Equal(blob[:8], test.header) {
+				t.Fatalf("header = % x, want % x", blob[:8], test.header)
+			}
+			envelopeEnd := 8 + len(test.envelope)*8
+			for ei, want := range test.envelope {
+				got := math.Float64frombits(
+					binary.LittleEndian.Uint64(blob[8+ei*8:]),
+				)
+				if got != want {
+					t.Errorf("envelope[%d] = %v, want %v", ei, got, want)
+				}
+			}
+			if !bytes.Equal(blob[envelopeEnd:], test.wkb) {
+				t.Errorf("WKB = % x, want % x", blob[envelopeEnd:], test.wkb)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "in.gpkg")
+	f, err := Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.AddSRS(SRS{
+		Name:           "NAD83",
+		ID:             4269,
+		Organization:   "EPSG",
+		OrganizationID: 4269,
+		Definition:     "GEOGCS[\"NAD83\"]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []*Table{
+		{
+			Name:        "census_tables",
+			Description: "Census tables",
+			Columns: []Column{
+				{Name: "table_name", Type: TypeText},
+				{Name: "description", Type: TypeText},
+			},
+		},
+		{
+			Name:        "geo_locations",
+			Description: "Geographic locations",
+			Columns: []Column{
+				{Name: "logrecno", Type: TypeInteger},
+				{Name: "location", Type: "POINT"},
+			},
+			PrimaryKey:     "logrecno",
+			GeometryColumn: "location",
+			GeometryType:   "POINT",
+			SRSID:          4269,
+		},
+	}
+	rows := [][]interface{}{
+		{"p1", "TOTAL POPULATION"},
+		{1, &Geometry{
+			WKB:  pointWKB(-86.15, 39.76),
+			MinX: -86.15, MaxX: -86.15, MinY: 39.76, MaxY: 39.76,
+		}},
+	}
+	for ti, table := range tables {
+		tw, err := f.CreateTable(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteRow(rows[ti]); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pragmas := []struct {
+		name string
+		want int
+	}{
+		{"application_id", ApplicationID},
+		{"user_version", UserVersion},
+	}
+	for _, pragma := range pragmas {
+		var got int
+		if err := db.QueryRow("PRAGMA " + pragma.name).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != pragma.want {
+			t.Errorf("%s = %d, want %d", pragma.name, got, pragma.want)
+		}
+	}
+
+	contents := []struct {
+		tableName string
+		dataType  string
+		srsID     sql.NullInt64
+		minX      sql.NullFloat64
+	}{
+		{"census_tables", "attributes", sql.NullInt64{}, sql.NullFloat64{}},
+		{
+			"geo_locations", "features",
+			sql.NullInt64{Int64: 4269, Valid: true},
+			sql.NullFloat64{Float64: -86.15, Valid: true},
+		},
+	}
+	for _, want := range contents {
+		var dataType string
+		var srsID sql.NullInt64
+		var minX sql.NullFloat64
+		err := db.QueryRow(
+			"SELECT data_type, srs_id, min_x FROM gpkg_contents "+
+				"WHERE table_name = ?", want.tableName,
+		).Scan(&dataType, &srsID, &minX)
+		if err != nil {
+			t.Fatalf("gpkg_contents row for %s: %s", want.tableName, err)
+		}
+		if dataType != want.dataType || srsID != want.srsID ||
+			minX != want.minX {
+			t.Errorf("gpkg_contents row for %s = (%s, %v, %v), "+
+				"want (%s, %v, %v)", want.tableName, dataType, srsID, minX,
+				want.dataType, want.srsID, want.minX,
+			)
+		}
+	}
+
+	var geometryColumnCount int
+	err = db.QueryRow("SELECT count(*) FROM gpkg_geometry_columns").Scan(
+		&geometryColumnCount,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geometryColumnCount != 1 {
+		t.Errorf("gpkg_geometry_columns has %d rows, want 1",
+			geometryColumnCount,
+		)
+	}
+	var columnName, geometryType string
+	var srsID int
+	err = db.QueryRow(
+		"SELECT column_name, geometry_type_name, srs_id "+
+			"FROM gpkg_geometry_columns WHERE table_name = 'geo_locations'",
+	).Scan(&columnName, &geometryType, &srsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columnName != "location" || geometryType != "POINT" || srsID != 4269 {
+		t.Errorf("gpkg_geometry_columns row = (%s, %s, %d)",
+			columnName, geometryType, srsID,
+		)
+	}
+
+	var indexed int
+	err = db.QueryRow(
+		"SELECT count(*) FROM rtree_geo_locations_location WHERE id = 1",
+	).Scan(&indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 1 {
+		t.Errorf("geo_locations row 1 is not in the spatial index")
+	}
+}
@@ -0,0 +1,175 @@
+// Package layout defines the geographic header record layouts of each census
+// product and vintage.  Layouts are read from the JSON specs in specs/ and
+// checked before use: fixed-width fields must not overlap and must cover the
+// whole record, and delimited layouts must have one field per column.
+package layout
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+)
+
+const FormatFixed = "fixed"
+const FormatDelimited = "delimited"
+
+//go:embed specs/*.json
+var specs embed.FS
+
+// A field of a geographic header record.  Size and Position (1-based, as in
+// the technical documentation) are only set in fixed-width layouts.
+type Field struct {
+	Name          string `json:"name"`
+	ReferenceName string `json:"reference_name"`
+	Size          int    `json:"size"`
+	Position      int    `json:"position"`
+	Numeric       bool   `json:"numeric"`
+}
+
+// A geographic header record layout.  RecordLength is the record's length in
+// characters for fixed-width layouts, and its number of fields for delimited
+// ones.
+type Layout struct {
+	Product      string  `json:"product"`
+	Vintage      int     `json:"vintage"`
+	Format       string  `json:"format"`
+	Delimiter    string  `json:"delimiter"`
+	RecordLength int     `json:"record_length"`
+	Fields       []Field `json:"fields"`
+}
+
+func (l *Layout) String() string {
+	return fmt.Sprintf("%s %d", l.Product, l.Vintage)
+}
+
+// Reads and checks the layout for a product and vintage.
+func Load(product string, vintage int) (*Layout, error) {
+	data, err := specs.ReadFile(
+		path.Join("specs", fmt.Sprintf("%s_%d.json", product, vintage)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("no %s %d geographic header layout (%s)",
+			product, vintage, err,
+		)
+	}
+
+	return parse(data)
+}
+
+// Reads and checks every bundled layout.
+func All() ([]*Layout, error) {
+	entries, err := specs.ReadDir("specs")
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := []*Layout{}
+	for _, entry := range entries {
+		data, err := specs.ReadFile(path.Join("specs", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		l, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", entry.Name(), err)
+		}
+		layouts = append(layouts, l)
+	}
+
+	return layouts, nil
+}
+
+func parse(data []byte) (*Layout, error) {
+	l := new(Layout)
+
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	if err := l.Check(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Returns an error describing the first problem with the layout, if any.
+func (l *Layout) Check() error {
+	if len(l.Fields) == 0 {
+		return fmt.Errorf("%s layout has no fields", l)
+	}
+
+	referenceNames := make(map[string]bool)
+	for _, field := range l.Fields {
+		if len(field.ReferenceName) == 0 {
+			return fmt.Errorf("%s layout has a field without a reference "+
+				"name (%s)", l, field.Name,
+			)
+		}
+		if referenceNames[field.ReferenceName] {
+			return fmt.Errorf("%s layout has field %s twice",
+				l, field.ReferenceName,
+			)
+		}
+		referenceNames[field.ReferenceName] = true
+	}
+
+	switch l.Format {
+	case FormatFixed:
+		return l.checkFixed()
+	case FormatDelimited:
+		if len(l.Delimiter) == 0 {
+			return fmt.Errorf("%s layout has no delimiter", l)
+		}
+		if len(l.Fields) != l.RecordLength {
+			return fmt.Errorf("%s layout has %d fields, records have %d",
+				l, len(l.Fields), l.RecordLength,
+			)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s layout has unknown format %q", l, l.Format)
+}
+
+// Checks that the fields, in position order, run from the first character
+// to the last without overlapping or leaving gaps.
+func (l *Layout) checkFixed() error {
+	fields := append([]Field{}, l.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Position < fields[j].Position
+	})
+
+	end := 0
+	previous := ""
+	for _, field := range fields {
+		if field.Size < 1 || field.Position < 1 {
+			return fmt.Errorf("%s layout: field %s has size %d at "+
+				"position %d", l, field.ReferenceName, field.Size,
+				field.Position,
+			)
+		}
+		if field.Position <= end {
+			return fmt.Errorf("%s layout: field %s at position %d "+
+				"overlaps %s, which ends at %d", l, field.ReferenceName,
+				field.Position, previous, end,
+			)
+		}
+		if field.Position > end+1 {
+			return fmt.Errorf("%s layout: positions %d-%d (before %s) "+
+				"are not covered", l, end+1, field.Position-1,
+				field.ReferenceName,
+			)
+		}
+		end = field.Position + field.Size - 1
+		previous = field.ReferenceName
+	}
+	if end != l.RecordLength {
+		return fmt.Errorf("%s layout ends at %d, records are %d long",
+			l, end, l.RecordLength,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSpecs(t *testing.T) {
+	tests := []struct {
+		product      string
+		vintage      int
+		format       string
+		recordLength int
+	}{
+		{"sf1", 2010, FormatFixed, 500},
+		{"pl", 2020, FormatDelimited, 97},
+	}
+
+	for _, test := range tests {
+		l, err := Load(test.product, test.vintage)
+		if err != nil {
+			t.Errorf("%s %d: %s", test.product, test.vintage, err)
+			continue
+		}
+		if l.Format != test.format {
+			t.Errorf("%s format = %s, want %s", l, l.Format, test.format)
+		}
+		if l.RecordLength != test.recordLength {
+			t.Errorf("%s record length = %d, want %d",
+				l, l.RecordLength, test.recordLength,
+			)
+		}
+	}
+
+	layouts, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layouts) != len(tests) {
+		t.Errorf("All() read %d layouts, want %d", len(layouts), len(tests))
+	}
+}
+
+// The 2010 SF1 layout with one field changed.
+func brokenSF1Layout(t *testing.T, change func(l *Layout)) *Layout {
+	t.Helper()
+	l, err := Load("sf1", 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Fields = append([]Field{}, l.Fields...)
+	change(l)
+
+	return l
+}
+
+func fieldIndex(l *Layout, referenceName string) int {
+	for fi, field := range l.Fields {
+		if field.ReferenceName == referenceName {
+			return fi
+		}
+	}
+
+	return -1
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(l *Layout)
+		err    string
+	}{
+		{
+			name:   "unchanged",
+			change: func(l *Layout) {},
+		},
+		{
+			name: "overlap",
+			change: func(l *Layout) {
+				l.Fields[fieldIndex(l, "SUMLEV")].Size = 4
+			},
+			err: "field GEOCOMP at position 12 overlaps SUMLEV, which ends at 12",
+		},
+		{
+			name: "gap",
+			change: func(l *Layout) {
+				l.Fields[fieldIndex(l, "SUMLEV")].Size = 2
+			},
+			err: "positions 11-11 (before GEOCOMP) are not covered",
+		},
+		{
+			name: "fields end before the record",
+			change: func(l *Layout) {
+				l.RecordLength = 501
+			},
+			err: "ends at 500, records are 501 long",
+		},
+		{
+			name: "zero size",
+			change: func(l *Layout) {
+				l.Fields[fieldIndex(l, "REGION")].Size = 0
+			},
+			err: "field REGION has size 0",
+		},
+		{
+			name: "duplicate field",
+			change: func(l *Layout) {
+				l.Fields[fieldIndex(l, "REGION")].ReferenceName = "DIVISION"
+			},
+			err: "has field DIVISION twice",
+		},
+		{
+			name: "missing reference name",
+			change: func(l *Layout) {
+				l.Fields[fieldIndex(l, "REGION")].ReferenceName = ""
+			},
+			err: "field without a reference name (Region)",
+		},
+		{
+			name: "no fields",
+			change: func(l *Layout) {
+				l.Fields = nil
+			},
+			err: "has no fields",
+		},
+		{
+			name: "unknown format",
+			change: func(l *Layout) {
+				l.Format = "csv"
+			},
+			err: `unknown format "csv"`,
+		},
+		{
+			name: "delimited without a delimiter",
+			change: func(l *Layout) {
+				l.Format = FormatDelimited
+				l.RecordLength = len(l.Fields)
+			},
+			err: "has no delimiter",
+		},
+		{
+			name: "delimited field count",
+			change: func(l *Layout) {
+				l.Format = FormatDelimited
+				l.Delimiter = "|"
+			},
+			err: "records have 500",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := brokenSF1Layout(t, test.change).Check()
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Errorf("got %s, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error, want %q", test.err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got %q, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -1,856 +1,3499 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"code.google.com/p/go-charset/charset"
-	_ "code.google.com/p/go-charset/data"
+	"context"
+	"crypto/sha256"
 	"database/sql"
-	"encoding/xml"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/camgunz/mapblue/backend/acs"
+	"github.com/camgunz/mapblue/backend/config"
+	"github.com/camgunz/mapblue/backend/dataset"
+	"github.com/camgunz/mapblue/backend/dictionary"
+	"github.com/camgunz/mapblue/backend/layout"
+	"github.com/camgunz/mapblue/backend/pl"
+	"github.com/camgunz/mapblue/backend/progress"
+	"github.com/camgunz/mapblue/backend/script"
+	"github.com/camgunz/mapblue/backend/sf1"
+	"github.com/camgunz/mapblue/backend/source"
+	"github.com/camgunz/mapblue/backend/tiger"
+	"github.com/lib/pq"
+	"hash"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
 	"path"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type XMLAPIConcepts struct {
-	XMLName  xml.Name         `xml:apivariables"`
-	Concepts []*XMLAPIConcept `xml:"concept"`
+// A census data file and the checksum of its contents, which is the same
+// whether the file is read from a folder or from an archive.  The checksum is
+// taken while the file is read for loading, or, if it's needed first (to
+// compare with the manifest when resuming), by reading the file on its own.
+type CensusDataFile struct {
+	File *source.File
+
+	checksum *fileChecksum
+}
+
+type fileChecksum struct {
+	lock  sync.Mutex
+	value string
 }
 
-type XMLAPIConcept struct {
-	XMLname   xml.Name          `xml:"concept"`
-	Name      string            `xml:"name,attr"`
-	Variables []*XMLAPIVariable `xml:"variable"`
+// Hashes what's read through it, recording the checksum once it reaches the
+// end of the file.
+type checksumReader struct {
+	io.ReadCloser
+
+	hash     hash.Hash
+	checksum *fileChecksum
 }
 
-type XMLAPIVariable struct {
-	XMLName     xml.Name `xml:"variable"`
-	Name        string   `xml:"name,attr"`
-	Description string   `xml:",chardata"`
+type CensusDataLocation struct {
+	State    string
+	DataFile CensusDataFile
+	Table    sf1.Table
+
+	// ACS tables are read from an estimate and a margin of error file for
+	// each sequence the table spans.
+	ACSTable      *acs.Table
+	EstimateFiles []CensusDataFile
+	MOEFiles      []CensusDataFile
 }
 
-type APIConcept struct {
+type CensusTable struct {
+	DataLocations []CensusDataLocation
 	Name          string
 	Description   string
-	VariableCount int
-	Variables     []APIVariable
+	Universe      string
+	RowCount      int
+	Columns       []CensusColumn
+	Progress      *progress.Table
+
+	// The leading identification columns, and the type of the columns
+	// after them.
+	KeyColumnCount       int
+	KeyColumnDefinitions string
+	ColumnType           string
 }
 
-type APIVariable struct {
-	Name        string
-	Description string
+type CensusColumn struct {
+	Name  string
+	Value string
+	Label string
 }
 
-type CensusDataFile struct {
-	Lock *sync.Mutex
-	File *os.File
+// Writes the rows of a single table inside a transaction, either with one
+// INSERT per row or with batched COPY statements.
+type TableWriter struct {
+	Tx          LoadTx
+	TableName   string
+	ColumnNames []string
+	Numeric     []bool
+	Method      string
+	RowCount    int
+	StartTime   time.Time
+	Progress    *progress.Table
+
+	dbTx              *sql.Tx
+	script            *script.Tx
+	insertStatement   *sql.Stmt
+	copyStatement     *sql.Stmt
+	copyBatchRowCount int
 }
 
-type CensusDataLocation struct {
-	DataFile     CensusDataFile
-	ColumnOffset int
-	ColumnCount  int
+// A load's transaction: a database transaction, or with -output a part of
+// the script the load is written to.
+type LoadTx interface {
+	Exec(query string, args ...interface{}) error
+	Commit() error
+	Rollback() error
 }
 
-type CensusTable struct {
-	DataLocation CensusDataLocation
-	Name         string
-	Description  string
-	RowCount     int
-	Columns      []CensusColumn
+// A database transaction whose Exec returns errors rather than exiting.
+type DBTx struct {
+	Tx *sql.Tx
 }
 
-type CensusColumn struct {
-	Name  string
-	Value string
+// The tables read from the same data segment file in each state, loaded
+// together so the file is read once.  ACS tables, which can span several
+// sequence files, are loaded on their own and have no Files.
+type CensusSegment struct {
+	Files  []CensusDataFile
+	Tables []*CensusTable
 }
 
-type GeoLocation struct {
-	Fields []GeoLocationField
+// Hands out database connections to segment loaders, which need one per
+// table for as long as they read the segment.  A loader takes all of its
+// connections at once, so loaders can't deadlock each holding part of what
+// they need.
+type ConnectionPool struct {
+	Size int
+
+	lock   sync.Mutex
+	tokens chan struct{}
 }
 
-type GeoLocationField struct {
-	Name          string
-	ReferenceName string
-	Size          int
-	Position      int
-	Numeric       bool
-	Value         string
+// Runs a load's workers.  When one fails the rest are cancelled, rolling back
+// the transactions they have open, and Wait returns the first error instead
+// of the process exiting with tables half written.
+type LoadGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
 }
 
-type GeoLocationFieldDescription struct {
-	Name          string
-	ReferenceName string
-	Size          int
-	Position      int
-	Numeric       bool
+// Collects every problem found by a validation run instead of stopping at the
+// first one.
+type ValidationReport struct {
+	Problems   []string
+	FileCount  int
+	TableCount int
 }
 
 var DB *sql.DB
+var CENSUS_DATA *source.Source
 var CENSUS_DATA_FILES = map[string]CensusDataFile{}
-var MAX_DB_CONNECTIONS int = 95
+var STATES []string
+var CONFIG *config.Config
 var PRINT_SQL_QUERIES bool = false
-
-var DATA_FILE_TEMPLATE = "in000%02d2010.sf1"
-var GEO_FILE = "ingeo2010.sf1"
-var PACKING_LIST_FILE = "in2010.sf1.prd.packinglist.txt"
-var DATA_DESCRIPTION_URL = "http://www.census.gov/developers/data/sf1.xml"
-var CONCEPT_REGEXP = regexp.MustCompile(`(.*)\.(.*)\[(\d\d*)\]`)
-var DATA_LOOKUP_REGEXP = regexp.MustCompile(`(.*)\|(\d\d*):(\d\d*)\|$`)
-var REQUIRED_FILES = []string{
-	"in000012010.sf1",
-	"in000022010.sf1",
-	"in000032010.sf1",
-	"in000042010.sf1",
-	"in000052010.sf1",
-	"in000062010.sf1",
-	"in000072010.sf1",
-	"in000082010.sf1",
-	"in000092010.sf1",
-	"in000102010.sf1",
-	"in000112010.sf1",
-	"in000122010.sf1",
-	"in000132010.sf1",
-	"in000142010.sf1",
-	"in000152010.sf1",
-	"in000162010.sf1",
-	"in000172010.sf1",
-	"in000182010.sf1",
-	"in000192010.sf1",
-	"in000202010.sf1",
-	"in000212010.sf1",
-	"in000222010.sf1",
-	"in000232010.sf1",
-	"in000242010.sf1",
-	"in000252010.sf1",
-	"in000262010.sf1",
-	"in000272010.sf1",
-	"in000282010.sf1",
-	"in000292010.sf1",
-	"in000302010.sf1",
-	"in000312010.sf1",
-	"in000322010.sf1",
-	"in000332010.sf1",
-	"in000342010.sf1",
-	"in000352010.sf1",
-	"in000362010.sf1",
-	"in000372010.sf1",
-	"in000382010.sf1",
-	"in000392010.sf1",
-	"in000402010.sf1",
-	"in000412010.sf1",
-	"in000422010.sf1",
-	"in000432010.sf1",
-	"in000442010.sf1",
-	"in000452010.sf1",
-	"in000462010.sf1",
-	"in000472010.sf1",
-	"in2010.sf1.prd.packinglist.txt",
-	"ingeo2010.sf1",
-}
-
-var GeoLocationFieldDescriptions = []GeoLocationFieldDescription{
-	{"File Identification", "FILEID", 6, 1, false},
-	{"State/U.S. Abbreviation", "STUSAB", 2, 7, false},
-	{"Summary Level", "SUMLEV", 3, 9, false},
-	{"Geographic Component", "GEOCOMP", 2, 12, false},
-	{"Characteristic Iteration", "CHARITER", 3, 14, false},
-	{"Characteristic Iteration File Sequence Number", "CIFSN", 2, 17, false},
-	{"Logical Record Number", "LOGRECNO", 7, 19, false},
-	{"Region", "REGION", 1, 26, false},
-	{"Division", "DIVISION", 1, 27, false},
-	{"State", "STATE", 2, 28, false},
-	{"County", "COUNTY", 3, 30, false},
-	{"County Class Code", "COUNTYCC", 2, 33, false},
-	{"County Size Code", "COUNTYSC", 2, 35, false},
-	{"County Subdivision", "COUSUB", 5, 37, false},
-	{"County Subdivision Class Code", "COUSUBCC", 2, 42, false},
-	{"County Subdivision Size Code", "COUSUBSC", 2, 44, false},
-	{"Place", "PLACE", 5, 46, false},
-	{"Place Class Code", "PLACECC", 2, 51, false},
-	{"Place Size Code", "PLACESC", 2, 53, false},
-	{"Census Tract", "TRACT", 6, 55, false},
-	{"Block Group", "BLKGRP", 1, 61, false},
-	{"Block", "BLOCK", 4, 62, false},
-	{"Internal Use Code", "IUC", 2, 66, false},
-	{"Consolidated City", "CONCIT", 5, 68, false},
-	{"Consolidated City Class Code", "CONCITCC", 2, 73, false},
-	{"Consolidated City Size Code", "CONCITSC", 2, 75, false},
-	{"American Indian Area/Alaska Native Area/Hawaiian Home Land (Census)", "AIANHH", 4, 77, false},
-	{"American Indian Area/Alaska Native Area/Hawaiian Home Land", "AIANHHFP", 5, 81, false},
-	{"American Indian Area/Alaska Native Area/Hawaiian Home Land Class Code", "AIANHHCC", 2, 86, false},
-	{"American Indian Trust Land/Hawaiian Home Land Indicator", "AIHHTLI", 1, 88, false},
-	{"American Indian Tribal Subdivision (Census)", "AITSCE", 3, 89, false},
-	{"American Indian Tribal Subdivision", "AITS", 5, 92, false},
-	{"American Indian Tribal Subdivision Class Code", "AITSCC", 2, 97, false},
-	{"Tribal Census Tract", "TTRACT", 6, 99, false},
-	{"Tribal Block Group", "TBLKGRP", 1, 105, false},
-	{"Alaska Native Regional Corporation", "ANRC", 5, 106, false},
-	{"Alaska Native Regional Corporation Class Code", "ANRCCC", 2, 111, false},
-	{"Metropolitan Statistical Area/Micropolitan Statistical Area", "CBSA", 5, 113, false},
-	{"Metropolitan Statistical Area/Micropolitan Statistical Area Size Code", "CBSASC", 2, 118, false},
-	{"Metropolitan Division", "METDIV", 5, 120, false},
-	{"Combined Statistical Area", "CSA", 3, 125, false},
-	{"New England City and Town Area", "NECTA", 5, 128, false},
-	{"New England City and Town Area Size Code", "NECTASC", 2, 133, false},
-	{"New England City and Town Area Division", "NECTADIV", 5, 135, false},
-	{"Combined New England City and Town Area", "CNECTA", 3, 140, false},
-	{"Metropolitan Statistical Area/Micropolitan Statistical Area Principal City Indicator", "CBSAPCI", 1, 143, false},
-	{"New England City and Town Area Principal City Indicator", "NECTAPCI", 1, 144, false},
-	{"Urban Area", "UA", 5, 145, false},
-	{"Urban Area Size Code", "UASC", 2, 150, false},
-	{"Urban Area Type", "UATYPE", 1, 152, false},
-	{"Urban/Rural", "UR", 1, 153, false},
-	{"Congressional District (111th)", "CD", 2, 154, false},
-	{"State Legislative District (Upper Chamber) (Year 1)", "SLDU", 3, 156, false},
-	{"State Legislative District (Lower Chamber) (Year 1)", "SLDL", 3, 159, false},
-	{"Voting District", "VTD", 6, 162, false},
-	{"Voting District Indicator", "VTDI", 1, 168, false},
-	{"Reserved", "RESERVE2", 3, 169, false},
-	{"ZIP Code Tabulation Area (5-digit)", "ZCTA5", 5, 172, false},
-	{"Subminor Civil Division", "SUBMCD", 5, 177, false},
-	{"Subminor Civil Division Class Code", "SUBMCDCC", 2, 182, false},
-	{"School District (Elementary)", "SDELM", 5, 184, false},
-	{"School District (Secondary)", "SDSEC", 5, 189, false},
-	{"School District (Unified)", "SDUNI", 5, 194, false},
-	{"Area (Land)", "AREALAND", 14, 199, true},
-	{"Area (Water)", "AREAWATR", 14, 213, true},
-	{"Area Name-Legal/Statistical Area Description", "NAME", 90, 227, false},
-	{"Functional Status Code", "FUNCSTAT", 1, 317, false},
-	{"Geographic Change User Note Indicator", "GCUNI", 1, 318, false},
-	{"Population Count (100%)", "POP100", 9, 319, true},
-	{"Housing Unit Count (100%)", "HU100", 9, 328, true},
-	{"Internal Point (Latitude)", "INTPTLAT", 11, 337, false},
-	{"Internal Point (Longitude)", "INTPTLON", 12, 348, false},
-	{"Legal/Statistical Area Description Code", "LSADC", 2, 360, false},
-	{"Part Flag", "PARTFLAG", 1, 362, false},
-	{"Reserved", "RESERVE3", 6, 363, false},
-	{"Urban Growth Area", "UGA", 5, 369, false},
-	{"State (ANSI)", "STATENS", 8, 374, false},
-	{"County (ANSI)", "COUNTYNS", 8, 382, false},
-	{"County Subdivision (ANSI)", "COUSUBNS", 8, 390, false},
-	{"Place (ANSI)", "PLACENS", 8, 389, false},
-	{"Consolidated City (ANSI)", "CONCITNS", 8, 406, false},
-	{"American Indian Area/Alaska Native Area/Hawaiian Home Land (ANSI)", "AIANHHNS", 8, 414, false},
-	{"American Indian Tribal Subdivision (ANSI)", "AITSNS", 8, 422, false},
-	{"Alaska Native Regional Corporation (ANSI)", "ANRCNS", 8, 430, false},
-	{"Subminor Civil Division (ANSI)", "SUBMCDNS", 8, 438, false},
-	{"Congressional District (113th)", "CD113", 2, 446, false},
-	{"Congressional District (114th)", "CD114", 2, 448, false},
-	{"Congressional District (115th)", "CD115", 2, 450, false},
-	{"State Legislative District (Upper Chamber) (Year 2)", "SLDU2", 3, 452, false},
-	{"State Legislative District (Lower Chamber) (Year 2)", "SLDU3", 3, 455, false},
-	{"State Legislative District (Upper Chamber) (Year 3)", "SLDU4", 3, 458, false},
-	{"State Legislative District (Lower Chamber) (Year 3)", "SLDL2", 3, 461, false},
-	{"State Legislative District (Upper Chamber) (Year 4)", "SLDL3", 3, 464, false},
-	{"State Legislative District (Lower Chamber) (Year 4)", "SLDL4", 3, 476, false},
-	{"American Indian Area/Alaska Native Area/Hawaiian Homeland size Code", "AIANHHSC", 2, 470, false},
-	{"Combined Statistical Area Size Code", "CSASC", 2, 472, false},
-	{"Combined NECTA Size Code", "CNECTASC", 2, 474, false},
-	{"Metropolitan/Micropolitan Indicator", "MEMI", 1, 476, false},
-	{"NECTA Metropolitan/Micropolitan Indicator", "NMEMI", 1, 477, false},
-	{"Public Use Microdata Area", "PUMA", 5, 478, false},
-	{"Reserved", "RESERVED", 18, 483, false},
-}
+var LOAD_METHOD = "copy"
+var COPY_BATCH_SIZE int = 10000
+var SEGMENT_QUEUE_SIZE = 100
+var WORKER_COUNT = 4
+var OUTPUT *script.Output
+var TOTAL_ROWS_WRITTEN int64 = 0
+var PROGRESS *progress.Tracker
+var RESUME bool = false
+var VALIDATE bool = false
+
+// Tables picked with -tables and -exclude-tables.  geo_locations is always
+// loaded and never goes through this check.
+var TABLE_SELECTION = &dataset.TableSelection{}
+var UNSELECTED_TABLES []string
+var SELECTED_TABLES []string
+var TABBLOCK_FILES []string
+var LOAD_MANIFEST = map[string]string{}
+var LIVE_MANIFEST = map[string]string{}
+
+var PRODUCT = ""
+var PRODUCTS = []string{"sf1", "acs5", "pl2020"}
+var VINTAGE = "2010"
+var ACS_LOOKUP *acs.Lookup
+var GEO_LAYOUT *layout.Layout
+var SCHEMA = ""
+var LIVE_SCHEMA = ""
+var STAGING bool = true
+var ROLLBACK bool = false
+
+var DEFAULT_STATES = "in"
+var DICTIONARY_FILE = ""
+
+var SF1_KEY_COLUMN_DEFINITIONS = "fileid varchar(6), stusab varchar(2), " +
+	"chariter varchar(3), cifsn varchar(3), logrecno varchar(7)"
+var ACS_KEY_COLUMN_DEFINITIONS = "fileid varchar(6), filetype varchar(6), " +
+	"stusab varchar(2), chariter varchar(3), sequence varchar(4), " +
+	"logrecno varchar(7)"
+
+// Block GEOIDs: STATE (2), COUNTY (3), TRACT (6) and BLOCK (4).
+var TABBLOCK_ID_LENGTH = 15
+
+// Tables and columns the block_demographics table is built from.
+var BLOCK_DEMOGRAPHICS_TABLES = []string{"p11", "p16", "p19", "p29"}
+var BLOCK_DEMOGRAPHICS_QUERY = "CREATE TABLE block_demographics AS " +
+	"SELECT DISTINCT ON (geoid) *, " +
+	"p0160003 AS over18, " +
+	"p0110006 AS black, " +
+	"p0110002 AS hispanic, " +
+	"p0110007 + p0110008 + p0110009 + p0110010 + p0110011 AS other_race, " +
+	"p0160003 - ((p0290007 * 2) + (p0290015 * 2)) AS unmarried, " +
+	"p0290018 + (p0190009 * 2) + p0190013 + p0190016 AS childless " +
+	"FROM (" +
+	"SELECT gl.state || gl.county || gl.tract || gl.block AS geoid, " +
+	"gl.stusab, gl.logrecno, tb.name, tb.the_geom, " +
+	"p11.p0110002, p11.p0110006, p11.p0110007, p11.p0110008, " +
+	"p11.p0110009, p11.p0110010, p11.p0110011, p16.p0160003, " +
+	"p19.p0190009, p19.p0190013, p19.p0190016, " +
+	"p29.p0290007, p29.p0290015, p29.p0290018 " +
+	"FROM geo_locations AS gl " +
+	"JOIN tabblock AS tb " +
+	"ON tb.tabblock_id = gl.state || gl.county || gl.tract || gl.block " +
+	"JOIN p11 ON p11.stusab = gl.stusab AND p11.logrecno = gl.logrecno " +
+	"JOIN p16 ON p16.stusab = gl.stusab AND p16.logrecno = gl.logrecno " +
+	"JOIN p19 ON p19.stusab = gl.stusab AND p19.logrecno = gl.logrecno " +
+	"JOIN p29 ON p29.stusab = gl.stusab AND p29.logrecno = gl.logrecno " +
+	"WHERE gl.sumlev = '101'" +
+	") AS blocks " +
+	"ORDER BY geoid, logrecno"
+
+// The 2020 redistricting data's equivalent, from P4 (Hispanic or Latino by
+// race for the population 18 and over).  It has no household tables, so
+// unmarried and childless are NULL.
+var PL_BLOCK_DEMOGRAPHICS_TABLES = []string{"p4"}
+var PL_BLOCK_DEMOGRAPHICS_QUERY = "CREATE TABLE block_demographics AS " +
+	"SELECT DISTINCT ON (geoid) *, " +
+	"p0040001 AS over18, " +
+	"p0040006 AS black, " +
+	"p0040002 AS hispanic, " +
+	"p0040007 + p0040008 + p0040009 + p0040010 + p0040011 AS other_race, " +
+	"NULL::integer AS unmarried, " +
+	"NULL::integer AS childless " +
+	"FROM (" +
+	"SELECT gl.state || gl.county || gl.tract || gl.block AS geoid, " +
+	"gl.stusab, gl.logrecno, tb.name, tb.the_geom, " +
+	"p4.p0040001, p4.p0040002, p4.p0040006, p4.p0040007, p4.p0040008, " +
+	"p4.p0040009, p4.p0040010, p4.p0040011 " +
+	"FROM geo_locations AS gl " +
+	"JOIN tabblock AS tb " +
+	"ON tb.tabblock_id = gl.state || gl.county || gl.tract || gl.block " +
+	"JOIN p4 ON p4.stusab = gl.stusab AND p4.logrecno = gl.logrecno " +
+	"WHERE gl.sumlev = '750'" +
+	") AS blocks " +
+	"ORDER BY geoid, logrecno"
 
 func printUsage(msg string) {
 	if len(msg) > 0 {
 		fmt.Fprintf(os.Stderr, "Error: %s\n\n", msg)
 	}
-	fmt.Fprintf(os.Stderr, "Usage: %s [census_data_folder]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] [census_data_folder]\n\n", os.Args[0])
 	fmt.Fprintln(os.Stderr,
-		"('census_data_folder' defaults to the current working folder)\n",
+		"('census_data_folder' defaults to the current working folder; it "+
+			"can hold the Census Bureau's zip archives, or be one)",
 	)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Options:")
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
-func openCensusDataFiles(censusDataFolder string) {
-	log.Printf("Loading census data from %s", censusDataFolder)
+func parseStates(states string) []string {
+	seen := make(map[string]bool)
+	parsedStates := []string{}
 
-	for _, fileName := range REQUIRED_FILES {
-		filePath := path.Join(censusDataFolder, fileName)
-		_, err := os.Stat(path.Join(censusDataFolder, fileName))
-		if err != nil {
-			printUsage(fmt.Sprintf(
-				"Census data at %s is incomplete, missing file %s (%s)",
-				censusDataFolder, fileName, err,
-			))
+	for _, state := range strings.Split(states, ",") {
+		state = strings.ToLower(strings.TrimSpace(state))
+		if len(state) == 0 {
+			continue
 		}
-
-		file, err := os.Open(filePath)
-		if err != nil {
-			log.Fatalf(
-				"Error opening census file %s (%s)\n", filePath, err,
-			)
+		if _, ok := sf1.States[state]; !ok {
+			printUsage(fmt.Sprintf("Unknown state %s", state))
 		}
-		CENSUS_DATA_FILES[fileName] = CensusDataFile{
-			File: file,
-			Lock: &sync.Mutex{},
+		if seen[state] {
+			continue
 		}
+		seen[state] = true
+		parsedStates = append(parsedStates, state)
 	}
-}
-
-func oldReadFileLines(tableName string, file *os.File, lineChan chan string) {
-	// buf := make([]byte, 64*1024)
-	buf := make([]byte, 30)
-	savedLine := make([]byte, 0, 64*1024)
 
-	if _, err := file.Seek(0, 0); err != nil {
-		log.Fatalf("Error rewinding file %s (%s)\n", file.Name(), err)
+	if len(parsedStates) == 0 {
+		printUsage("No states given")
 	}
 
-	for {
-		if bytesRead, err := file.Read(buf); err != nil {
-			if bytesRead == 0 && err == io.EOF {
-				break
-			} else {
-				log.Fatalf("Error reading from file %s (%s)\n", file.Name, err)
-			}
-		} else if bytesRead > 0 {
-			lines := bytes.Split(buf, []byte{'\n'})
-			lineCount := len(lines)
-			if lineCount == 0 {
-				continue
-			}
-			if lineCount >= 1 {
-				savedLine = append(savedLine, lines[0]...)
-			}
-			if lineCount >= 2 {
-				lineChan <- string(savedLine[:])
-				savedLine = savedLine[0:0]
-			}
-			if lineCount > 2 {
-				for _, line := range lines[1 : len(lines)-2] {
-					lineChan <- string(line[:])
-				}
-			}
-			if lineCount >= 2 {
-				savedLine = append(savedLine, lines[len(lines)-1]...)
-			}
-		}
-	}
-	close(lineChan)
+	return parsedStates
 }
 
-func openDB() {
-	db, err := sql.Open(
-		"postgres",
-		"host=/var/run/postgresql dbname=census "+
-			"user=census sslmode=disable",
-	)
+func openCensusDataFile(fileName string) {
+	file, err := CENSUS_DATA.File(fileName)
 	if err != nil {
-		log.Fatalf(
-			"Error connecting to database (%s)\n", err,
-		)
+		printUsage(fmt.Sprintf(
+			"Census data at %s is incomplete (%s)", CENSUS_DATA.Path, err,
+		))
 	}
-	DB = db
-	DB.SetMaxOpenConns(MAX_DB_CONNECTIONS)
-}
 
-func closeDB() {
-	if DB != nil {
-		if err := DB.Close(); err != nil {
-			log.Printf("Error closing database connection (%s)\n", err)
-		}
+	CENSUS_DATA_FILES[fileName] = CensusDataFile{
+		File:     file,
+		checksum: new(fileChecksum),
 	}
 }
 
-func reopenDB() {
-	closeDB()
-	openDB()
-}
-
-func dbBegin() *sql.Tx {
-	tx, err := DB.Begin()
-
+// Opens the file for loading.  Reading it to the end records its checksum.
+func (dataFile CensusDataFile) Open() (io.ReadCloser, error) {
+	reader, err := openCensusFile(dataFile.File)
 	if err != nil {
-		log.Fatalf("Error beginning transaction (%s)\n", err)
+		return nil, err
 	}
 
-	return tx
+	return &checksumReader{
+		ReadCloser: reader,
+		hash:       sha256.New(),
+		checksum:   dataFile.checksum,
+	}, nil
 }
 
-func dbExec(tx *sql.Tx, query string) {
-	if tx == nil {
-		if _, err := DB.Exec(query); err != nil {
-			log.Fatalf("Query error: %s\nQuery: %s\n", err, query)
-		} else if PRINT_SQL_QUERIES {
-			fmt.Println(query)
-		}
-	} else if _, err := tx.Exec(query); err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			log.Fatalf("Error rolling back transaction\n"+
-				"Query error: %s\n"+
-				"Rollback error: %s\n"+
-				"Query: %s\n",
-				err, rbErr, query,
-			)
-		} else {
-			log.Fatalf("Query error: %s\nQuery: %s\n", err, query)
-		}
-	} else if PRINT_SQL_QUERIES {
-		fmt.Println(query)
+func (reader *checksumReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	reader.hash.Write(p[:n])
+	if err == io.EOF {
+		reader.checksum.lock.Lock()
+		reader.checksum.value = hex.EncodeToString(reader.hash.Sum(nil))
+		reader.checksum.lock.Unlock()
 	}
+
+	return n, err
 }
 
-func dbExecIgnoreError(tx *sql.Tx, query string) {
-	if tx == nil {
-		if _, err := DB.Exec(query); err != nil {
-			log.Printf("Query error: %s\nQuery: %s\n", err, query)
-		} else if PRINT_SQL_QUERIES {
-			fmt.Println(query)
+// Returns the file's checksum, reading the whole file if it hasn't been read
+// for loading yet.
+func (dataFile CensusDataFile) Checksum() (string, error) {
+	dataFile.checksum.lock.Lock()
+	defer dataFile.checksum.lock.Unlock()
+
+	if len(dataFile.checksum.value) == 0 {
+		reader, err := openCensusFile(dataFile.File)
+		if err != nil {
+			return "", err
 		}
-	} else if _, err := tx.Exec(query); err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			log.Fatalf("Error rolling back transaction\n"+
-				"Query error: %s\n"+
-				"Rollback error: %s\n"+
-				"Query: %s\n",
-				err, rbErr, query,
-			)
-		} else {
-			log.Printf("Query error: %s\nQuery: %s\n", err, query)
+		defer reader.Close()
+		checksum, err := readerChecksum(dataFile.File.Path, reader)
+		if err != nil {
+			return "", err
 		}
-	} else if PRINT_SQL_QUERIES {
-		fmt.Println(query)
+		dataFile.checksum.value = checksum
 	}
-}
 
-func dbCommit(tx *sql.Tx) {
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("Error committing transaction (%s)\n", err)
-	}
+	return dataFile.checksum.value, nil
 }
 
-func GetAPIConcepts() map[string]APIConcept {
-	log.Println("Downloading census API documentation")
-	xmlAPIConcepts := new(XMLAPIConcepts)
-	apiConcepts := make(map[string]APIConcept)
-
-	resp, err := http.Get(DATA_DESCRIPTION_URL)
+func openCensusFile(file *source.File) (io.ReadCloser, error) {
+	reader, err := file.Open()
 	if err != nil {
-		log.Fatalln(err)
+		return nil, fmt.Errorf("error opening census file %s (%s)",
+			file.Path, err,
+		)
 	}
 
-	decoder := xml.NewDecoder(resp.Body)
-	decoder.CharsetReader = charset.NewReader
+	return reader, nil
+}
 
-	if err := decoder.Decode(xmlAPIConcepts); err != nil {
-		log.Fatalln(err)
-	}
+func readerChecksum(filePath string, reader io.Reader) (string, error) {
+	hash := sha256.New()
 
-	for _, xmlConcept := range xmlAPIConcepts.Concepts {
-		var apiConcept APIConcept
-		// apiConcept := new(APIConcept)
-
-		match := CONCEPT_REGEXP.FindStringSubmatch(xmlConcept.Name)
-		if len(match) == 0 {
-			if xmlConcept.Name == "Geographic Characteristics" {
-				apiConcept.Name = "geo_locations"
-				apiConcept.Description = "Geographic Characteristics"
-				apiConcept.VariableCount = 33
-			} else if strings.HasPrefix(xmlConcept.Name, "PCT22A") {
-				apiConcept.Name = "pct22a"
-				apiConcept.Description =
-					"GROUP QUARTERS POPULATION BY SEX BY GROUP QUARTERS " +
-						"TYPE FOR THE POPULATION 18 YEARS AND OVER (WHITE ALONE)"
-				apiConcept.VariableCount = 21
-			} else if strings.HasPrefix(xmlConcept.Name, "PCT22D") {
-				apiConcept.Name = "pct22d"
-				apiConcept.Description =
-					"GROUP QUARTERS POPULATION BY SEX BY GROUP QUARTERS " +
-						"TYPE FOR THE POPULATION 18 YEARS AND OVER (ASIAN ALONE)"
-				apiConcept.VariableCount = 21
-			} else {
-				log.Fatalf(
-					"Concept %s does not match regex\n", xmlConcept.Name,
-				)
-			}
-		} else {
-			apiConcept.Name = strings.ToLower(match[1])
-			apiConcept.Description = strings.TrimSpace(match[2])
-			num, err := strconv.ParseInt(match[3], 10, 32)
-			if err != nil {
-				log.Fatalln(err)
-			}
-			apiConcept.VariableCount = int(num)
-		}
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", fmt.Errorf("error reading file %s (%s)", filePath, err)
+	}
 
-		for _, variable := range xmlConcept.Variables {
-			var apiVariable APIVariable
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-			apiVariable.Name = strings.ToLower(variable.Name)
-			apiVariable.Description = variable.Description
-			apiConcept.Variables = append(apiConcept.Variables, apiVariable)
-		}
-		if len(apiConcept.Variables) != apiConcept.VariableCount {
-			log.Fatalf("Mismatched variable count for %s: %d != %d\n",
-				apiConcept.Name,
-				apiConcept.VariableCount,
-				len(apiConcept.Variables),
-			)
-		}
-		apiConcepts[apiConcept.Name] = apiConcept
+func readPackingList(file *source.File) (*sf1.PackingList, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
 
-	return apiConcepts
+	return sf1.ReadPackingList(reader)
 }
 
-func GetGeoLocations(queue chan *GeoLocation) {
-	scanner := bufio.NewScanner(CENSUS_DATA_FILES[GEO_FILE].File)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) == 0 {
-			continue
-		}
-		geoLocation := new(GeoLocation)
-		for _, fd := range GeoLocationFieldDescriptions {
-			var geoLocationField GeoLocationField
+// Reads the names of the geographic header and data segment files from a
+// state's packing list.
+func getRequiredFiles(state string) []string {
+	packingListFile := CENSUS_DATA_FILES[sf1.PackingListFileName(state)].File
 
-			startIndex := fd.Position - 1
-			endIndex := startIndex + fd.Size
-			if startIndex < 0 ||
-				startIndex == endIndex ||
-				startIndex >= len(line) ||
-				endIndex > len(line) {
-				log.Fatalf(
-					"Value indices for %s (len: %d, %d:%d) out of range\n",
-					fd.Name, len(line), startIndex, endIndex,
-				)
-			}
-			geoLocationField.Name = fd.Name
-			geoLocationField.ReferenceName = fd.ReferenceName
-			geoLocationField.Size = fd.Size
-			geoLocationField.Position = fd.Position
-			geoLocationField.Numeric = fd.Numeric
-			geoLocationField.Value = strings.TrimSpace(
-				line[startIndex:endIndex],
+	packingList, err := readPackingList(packingListFile)
+	if err != nil {
+		log.Fatalf("Error reading packing list file %s (%s)\n",
+			packingListFile.Path, err,
+		)
+	}
+	requiredFiles := packingList.Files
+	for _, fileName := range requiredFiles {
+		if !strings.HasPrefix(fileName, state) {
+			log.Fatalf("Packing list %s names file %s from another state\n",
+				packingListFile.Path, fileName,
 			)
-			geoLocation.Fields = append(geoLocation.Fields, geoLocationField)
 		}
-		queue <- geoLocation
 	}
-	close(queue)
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error reading packing list file (%s)", err)
+
+	if len(requiredFiles) == 0 {
+		log.Fatalf("Packing list %s lists no data files\n",
+			packingListFile.Path,
+		)
 	}
+
+	return requiredFiles
 }
 
-func GetDataTables(queue chan *CensusTable) {
-	api := GetAPIConcepts()
-	fileOffsets := make(map[string]int)
+func openCensusDataFiles() {
+	log.Printf("Loading census data from %s", CENSUS_DATA.Path)
 
-	scanner := bufio.NewScanner(CENSUS_DATA_FILES[PACKING_LIST_FILE].File)
-	for scanner.Scan() {
-		dataTable := new(CensusTable)
+	if PRODUCT == "acs5" {
+		openACSDataFiles()
+		return
+	}
+	if PRODUCT == "pl2020" {
+		openPLDataFiles()
+		return
+	}
 
-		line := scanner.Text()
-		match := DATA_LOOKUP_REGEXP.FindStringSubmatch(line)
-		if len(match) == 0 {
-			continue
+	for _, state := range STATES {
+		log.Printf("Opening census data files for %s\n", sf1.States[state])
+		openCensusDataFile(sf1.PackingListFileName(state))
+		for _, fileName := range getRequiredFiles(state) {
+			openCensusDataFile(fileName)
 		}
-		tableName := match[1]
-		num, err := strconv.ParseInt(match[2], 10, 32)
-		if err != nil {
-			log.Fatalf(
-				"Error parsing file number from packing list file (%s)\n", err,
+		if _, ok := CENSUS_DATA_FILES[sf1.GeoFileName(state)]; !ok {
+			log.Fatalf("Packing list %s does not list geographic file %s\n",
+				sf1.PackingListFileName(state), sf1.GeoFileName(state),
 			)
 		}
-		fileNumber := int(num)
-		num, err = strconv.ParseInt(match[3], 10, 32)
+	}
+}
+
+// Finds and reads the ACS sequence and table number lookup file.
+func readACSLookup() (*acs.Lookup, string, error) {
+	for _, fileName := range acs.LookupFileNames {
+		file, err := CENSUS_DATA.File(fileName)
 		if err != nil {
-			log.Fatalf(
-				"Error parsing column count from packing list file (%s)\n",
-				err,
-			)
-		}
-		columnCount := int(num)
-		dataFileName := fmt.Sprintf(DATA_FILE_TEMPLATE, fileNumber)
-		if _, present := CENSUS_DATA_FILES[dataFileName]; !present {
-			log.Fatalf(
-				"Census data file %s not recognized "+
-					"(built from file number %d for table %s)\n",
-				dataFileName, fileNumber, tableName,
-			)
-		}
-		columnOffset := 0
-		if offset, ok := fileOffsets[dataFileName]; ok {
-			columnOffset = offset
-		} else {
-			fileOffsets[dataFileName] = 0
-		}
-		fileOffsets[dataFileName] += columnCount
-		apiConcept, ok := api[tableName]
-		if !ok {
-			log.Fatalf("API data lacks table %s\n", tableName)
+			continue
 		}
-		dataTable.DataLocation.DataFile = CENSUS_DATA_FILES[dataFileName]
-		dataTable.DataLocation.ColumnOffset = columnOffset
-		dataTable.DataLocation.ColumnCount = columnCount
-		dataTable.Name = tableName
-		dataTable.Description = apiConcept.Description
-		dataTable.RowCount = 0
-		dataTable.Columns = append(
-			dataTable.Columns, CensusColumn{Name: "fileid"},
-		)
-		dataTable.Columns = append(
-			dataTable.Columns, CensusColumn{Name: "stusab"},
-		)
-		dataTable.Columns = append(
-			dataTable.Columns, CensusColumn{Name: "chariter"},
-		)
-		dataTable.Columns = append(
-			dataTable.Columns, CensusColumn{Name: "cifsn"},
-		)
-		dataTable.Columns = append(
-			dataTable.Columns, CensusColumn{Name: "logrecno"},
-		)
-		for _, apiVariable := range apiConcept.Variables {
-			dataTable.Columns = append(
-				dataTable.Columns, CensusColumn{Name: apiVariable.Name},
-			)
+		reader, err := file.Open()
+		if err != nil {
+			return nil, file.Path, err
 		}
+		defer reader.Close()
 
-		if len(dataTable.Columns)-5 != dataTable.DataLocation.ColumnCount {
-			log.Fatalf("Found column count mismatch in table %s (%d != %d)\n",
-				dataTable.Name, len(dataTable.Columns),
-				dataTable.DataLocation.ColumnCount,
-			)
-		}
-		queue <- dataTable
-	}
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error reading packing list file (%s)", err)
+		lookup, err := acs.ReadLookup(reader)
+		return lookup, file.Path, err
 	}
-	close(queue)
+
+	return nil, "", fmt.Errorf("no lookup file (%s) in %s",
+		strings.Join(acs.LookupFileNames, ", "), CENSUS_DATA.Path,
+	)
 }
 
-func loadGeoLocationData(geoLocationDataLoaded chan bool) {
-	log.Println("Loading geographic location data")
+// Works out the ACS release from the names of the states' geography files,
+// e.g. "2013" from g20135in.csv.
+func findACSVintage() (string, error) {
+	vintages := make(map[string][]string)
+	for _, fileName := range CENSUS_DATA.Names() {
+		match := acs.GeoFileRegexp.FindStringSubmatch(fileName)
+		if len(match) > 0 {
+			vintages[match[2]] = append(vintages[match[2]], match[1])
+		}
+	}
 
-	dropGeoLocationsTableQuery := "DROP TABLE geo_locations"
-	createGeoLocationsTableQuery :=
-		"CREATE TABLE geo_locations (id SERIAL PRIMARY KEY"
-	for _, gvfd := range GeoLocationFieldDescriptions {
-		if gvfd.ReferenceName == "AREALAND" ||
-			gvfd.ReferenceName == "AREAWATR" {
-			createGeoLocationsTableQuery += fmt.Sprintf(
-				", %s bigint", gvfd.ReferenceName,
+	vintage := ""
+	for _, state := range STATES {
+		stateVintages := vintages[state]
+		if len(stateVintages) == 0 {
+			return "", fmt.Errorf("no ACS geography file for %s in %s",
+				sf1.States[state], CENSUS_DATA.Path,
 			)
-		} else if gvfd.Numeric {
-			createGeoLocationsTableQuery += fmt.Sprintf(
-				", %s integer", gvfd.ReferenceName,
+		}
+		if len(stateVintages) > 1 {
+			return "", fmt.Errorf("several ACS releases for %s in %s (%s)",
+				sf1.States[state], CENSUS_DATA.Path,
+				strings.Join(stateVintages, ", "),
 			)
-		} else {
-			createGeoLocationsTableQuery += fmt.Sprintf(
-				", %s varchar (%d)", gvfd.ReferenceName, gvfd.Size,
+		}
+		if len(vintage) > 0 && stateVintages[0] != vintage {
+			return "", fmt.Errorf("ACS releases %s and %s are mixed in %s",
+				vintage, stateVintages[0], CENSUS_DATA.Path,
 			)
 		}
+		vintage = stateVintages[0]
 	}
-	createGeoLocationsTableQuery += ")"
 
-	dbExecIgnoreError(nil, dropGeoLocationsTableQuery)
-	dbExec(nil, createGeoLocationsTableQuery)
-	log.Println("Created table 'geo_locations'")
+	return vintage, nil
+}
 
-	geoTableColumnNameSlice := make([]string, len(GeoLocationFieldDescriptions))
-	for fi, fd := range GeoLocationFieldDescriptions {
-		geoTableColumnNameSlice[fi] = fd.ReferenceName
+// Opens the ACS lookup file, then each state's geography file and the
+// estimate and margin of error files of every sequence it names.
+func openACSDataFiles() {
+	lookup, lookupPath, err := readACSLookup()
+	if err != nil {
+		log.Fatalf("Error reading ACS lookup file %s (%s)\n", lookupPath, err)
 	}
-	geoTableColumnNames := strings.Join(geoTableColumnNameSlice, ", ")
-
-	geoLocationQueue := make(chan *GeoLocation, 10)
-	go GetGeoLocations(geoLocationQueue)
-	tx := dbBegin()
-	for geoLocation := range geoLocationQueue {
-		rowValueSlice := make([]string, len(geoLocation.Fields))
-		for fi, field := range geoLocation.Fields {
-			if field.Numeric {
-				rowValueSlice[fi] = field.Value
-			} else {
-				rowValueSlice[fi] = fmt.Sprintf("'%s'", field.Value)
-			}
+	ACS_LOOKUP = lookup
+	vintage, err := findACSVintage()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	VINTAGE = vintage
+	log.Printf("Loading the %s ACS 5-year release\n", VINTAGE)
+
+	for _, state := range STATES {
+		log.Printf("Opening census data files for %s\n", sf1.States[state])
+		openCensusDataFile(acs.GeoFileName(VINTAGE, state))
+		for _, sequence := range ACS_LOOKUP.Sequences {
+			openCensusDataFile(acs.EstimateFileName(VINTAGE, state, sequence))
+			openCensusDataFile(acs.MOEFileName(VINTAGE, state, sequence))
 		}
-		geoLocationQuery := fmt.Sprintf(
-			"INSERT INTO geo_locations (%s) VALUES (%s)",
-			geoTableColumnNames, strings.Join(rowValueSlice, ", "),
-		)
-		dbExec(tx, geoLocationQuery)
 	}
-	dbCommit(tx)
+}
 
-	geoLocationDataLoaded <- true
+// Opens each state's 2020 redistricting data files, which have no packing
+// list.
+func openPLDataFiles() {
+	for _, state := range STATES {
+		log.Printf("Opening census data files for %s\n", sf1.States[state])
+		openCensusDataFile(pl.GeoFileName(state))
+		for fileNumber := 1; fileNumber <= pl.SegmentCount; fileNumber++ {
+			openCensusDataFile(pl.DataFileName(state, fileNumber))
+		}
+	}
 }
 
-func loadCensusData(censusDataLoaded chan bool) {
-	dataTableQueue := make(chan *CensusTable, 10)
-	tableLoadedChan := make(chan string, 10)
+// Works out which census product the data folder holds from the first
+// state's files.
+func detectProduct() string {
+	if _, err := CENSUS_DATA.File(pl.GeoFileName(STATES[0])); err == nil {
+		return "pl2020"
+	}
+	if _, err := findACSVintage(); err == nil {
+		return "acs5"
+	}
 
-	go GetDataTables(dataTableQueue)
+	return "sf1"
+}
 
-	tableCount := 0
-	for dataTable := range dataTableQueue {
-		log.Printf("Loading census data table %s\n", dataTable.Name)
-		go loadCensusDataTable(dataTable, tableLoadedChan)
-		tableCount++
+// Checks every bundled geographic header layout, refusing to run with a
+// broken one, then picks the layout of the product being loaded.  ACS
+// geography files are comma-separated with a fixed set of columns.
+func loadGeoLayout() {
+	if _, err := layout.All(); err != nil {
+		log.Fatalf("Broken geographic header layout (%s)\n", err)
 	}
 
-	for {
-		loadedTableName := <-tableLoadedChan
-		tableCount--
-		log.Printf("Loaded table %s, %d to go\n", loadedTableName, tableCount)
-		if tableCount == 0 {
-			break
-		}
+	var err error
+	switch PRODUCT {
+	case "sf1":
+		GEO_LAYOUT, err = layout.Load("sf1", 2010)
+	case "pl2020":
+		GEO_LAYOUT, err = layout.Load("pl", 2020)
+	}
+	if err != nil {
+		log.Fatalln(err)
 	}
-
-	censusDataLoaded <- true
 }
 
-func loadCensusDataTable(dataTable *CensusTable, tableLoaded chan string) {
-	startIndex := dataTable.DataLocation.ColumnOffset + 5
-	endIndex := startIndex + dataTable.DataLocation.ColumnCount
+// Returns the name of a state's geographic file for the product being
+// loaded.
+func geoFileName(state string) string {
+	if PRODUCT == "acs5" {
+		return acs.GeoFileName(VINTAGE, state)
+	}
+	if PRODUCT == "pl2020" {
+		return pl.GeoFileName(state)
+	}
 
-	columnDefinitionSlice := make([]string, len(dataTable.Columns)-5)
-	for ci, column := range dataTable.Columns {
-		if ci < 5 { // skip the first 5 geographic location columns
-			continue
-		}
-		columnDefinitionSlice[ci-5] = fmt.Sprintf(
-			"%s integer", column.Name,
-		)
+	return sf1.GeoFileName(state)
+}
+
+// Returns the data segment field delimiter for the product being loaded.
+func segmentDelimiter() string {
+	if PRODUCT == "pl2020" {
+		return pl.Delimiter
 	}
-	columnDefinitions := strings.Join(columnDefinitionSlice, ", ")
-	dropDataTableQuery := fmt.Sprintf("DROP TABLE %s", dataTable.Name)
-	createDataTableQuery := fmt.Sprintf(
-		"CREATE TABLE %s ("+
-			"id SERIAL PRIMARY KEY, fileid varchar(6), stusab varchar(2), "+
-			"chariter varchar(3), cifsn varchar(3), logrecno varchar(7), %s"+
-			")", dataTable.Name, columnDefinitions,
-	)
 
-	dbExecIgnoreError(nil, dropDataTableQuery)
-	dbExec(nil, createDataTableQuery)
-	log.Printf("Created table '%s'\n", dataTable.Name)
+	return ","
+}
 
-	columnNameSlice := make([]string, len(dataTable.Columns))
-	for ci, column := range dataTable.Columns {
-		columnNameSlice[ci] = column.Name
+func openDB() {
+	db, err := dataset.Open(CONFIG)
+	if err != nil {
+		log.Fatalf("Error connecting to database (%s)\n", err)
 	}
-	columnNames := strings.Join(columnNameSlice, ", ")
+	DB = db
+}
 
-	dataTable.DataLocation.DataFile.Lock.Lock()
-	defer dataTable.DataLocation.DataFile.Lock.Unlock()
+// Names the target schema.  "auto" names it after the product, vintage and
+// states, e.g. sf1_2010_in or pl_2020_in_oh.
+func resolveSchema(schema string) string {
+	if schema != "auto" {
+		return schema
+	}
 
-	lineCount := 0
-	rowCount := 0
-	if _, err := dataTable.DataLocation.DataFile.File.Seek(0, 0); err != nil {
-		log.Fatalf("Error rewinding file %s (%s)\n",
-			dataTable.DataLocation.DataFile.File.Name(),
-			err,
-		)
+	product := PRODUCT
+	if product == "pl2020" {
+		product = "pl"
 	}
-	scanner := bufio.NewScanner(dataTable.DataLocation.DataFile.File)
-	tx := dbBegin()
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineCount++
-		rowValues := strings.Split(line, ",")
-		if startIndex < 5 || endIndex > len(rowValues) {
-			log.Fatalf(
-				"Row value indices for %s out of range: [%d:%d] (%d) =>\n"+
-					"rowValues: %s\n"+
-					"file: %s\n"+
-					"line (%d, %d): %s\n",
-				dataTable.Name, startIndex, endIndex, len(rowValues),
-				rowValues, dataTable.DataLocation.DataFile.File.Name(),
-				lineCount, len(line), line,
-			)
-		}
-		tableValues := append(rowValues[0:5], rowValues[startIndex:endIndex]...)
-		for ci, columnValue := range tableValues {
-			dataTable.Columns[ci].Value = columnValue
-		}
-		columnValueSlice := make([]string, len(dataTable.Columns))
-		for ci, column := range dataTable.Columns {
-			if ci < 5 {
-				columnValueSlice[ci] = fmt.Sprintf("'%s'", column.Value)
-			} else {
-				columnValueSlice[ci] = column.Value
+
+	return fmt.Sprintf("%s_%s_%s", product, VINTAGE, strings.Join(STATES, "_"))
+}
+
+// Sets up the schema tables are loaded into.  With staging, that's the live
+// schema's <schema>_staging, whose tables are swapped into the live schema
+// once the load has been checked.
+func setUpSchemas() {
+	LIVE_SCHEMA = SCHEMA
+	if STAGING {
+		if len(LIVE_SCHEMA) == 0 {
+			LIVE_SCHEMA = currentSchema()
+		}
+		SCHEMA = LIVE_SCHEMA + config.StagingSuffix
+		for _, schema := range []string{SCHEMA, previousSchema()} {
+			if err := config.CheckDataset(schema); err != nil {
+				log.Fatalf("Can't stage tables for schema %s (%s)\n",
+					LIVE_SCHEMA, err,
+				)
 			}
 		}
-		dataQuery := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES (%s)",
-			dataTable.Name, columnNames, strings.Join(columnValueSlice, ", "),
-		)
-		dbExec(tx, dataQuery)
-		rowCount++
+		if !RESUME {
+			// Tables left over from a failed run would be swapped in too
+			dbExec(nil,
+				"DROP SCHEMA IF EXISTS "+quoteIdentifier(SCHEMA)+" CASCADE",
+			)
+		}
 	}
-	dbCommit(tx)
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error reading from file %s (%s)\n",
-			dataTable.DataLocation.DataFile.File.Name(), err,
-		)
+	if len(SCHEMA) == 0 {
+		return
 	}
-	log.Printf("%s: wrote %d rows\n", dataTable.Name, rowCount)
 
-	tableLoaded <- dataTable.Name
+	dbExec(nil, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(SCHEMA))
+	log.Printf("Loading into schema '%s'\n", SCHEMA)
 }
 
-func main() {
-	var censusDataFolder string
-	geoLocationDataLoaded := make(chan bool)
-	censusDataLoaded := make(chan bool)
-
-	// Check for a specified census data folder
-	if len(os.Args) == 1 {
-		dataFolder, err := os.Getwd()
-		if err != nil {
-			log.Fatalf(
-				"Could not get current working folder: %s\n", err,
-			)
-		}
-		censusDataFolder = dataFolder
-	} else if len(os.Args) == 2 {
-		censusDataFolder = os.Args[1]
-	} else {
-		printUsage("")
+// Starts the script a load is written to with -output.  The script replaces
+// tables in place, in one transaction, so there's nothing to stage.
+func openScriptOutput(outputPath string, format string, censusDataFolder string) {
+	restore := "psql -f " + path.Base(outputPath)
+	if format == script.FormatTSV {
+		restore = "cd into this folder, then psql -f " + script.TSVScriptName
 	}
+	header := fmt.Sprintf("Census data for %s (%s %s), loaded from %s\n"+
+		"Restore with: %s",
+		strings.Join(STATES, ", "), PRODUCT, VINTAGE, censusDataFolder,
+		restore,
+	)
 
-	// Check that the census data folder exists and is a folder
-	fi, err := os.Stat(censusDataFolder)
+	output, err := script.Open(outputPath, format, header)
 	if err != nil {
-		printUsage(fmt.Sprintf(
-			"Could not locate folder %s", censusDataFolder,
+		log.Fatalf("Error opening script %s (%s)\n", outputPath, err)
+	}
+	OUTPUT = output
+	LOAD_METHOD = "script"
+	STAGING = false
+	LIVE_SCHEMA = SCHEMA
+
+	if len(SCHEMA) > 0 {
+		dbExec(nil, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(SCHEMA))
+	}
+	createLoadManifest(nil, SCHEMA)
+	log.Printf("Writing script %s\n", OUTPUT.ScriptPath)
+}
+
+func currentSchema() string {
+	schema, err := dataset.CurrentSchema(DB)
+	if err != nil {
+		log.Fatalf("Error finding the schema (%s)\n", err)
+	}
+
+	return schema
+}
+
+func previousSchema() string {
+	return LIVE_SCHEMA + config.PreviousSuffix
+}
+
+func closeDB() {
+	if DB != nil {
+		if err := DB.Close(); err != nil {
+			log.Printf("Error closing database connection (%s)\n", err)
+		}
+	}
+}
+
+func reopenDB() {
+	closeDB()
+	openDB()
+}
+
+// With -output, dbBegin, dbExec and dbCommit write to the script instead of
+// the database.
+func dbBegin() *sql.Tx {
+	if OUTPUT != nil {
+		return nil
+	}
+	tx, err := DB.Begin()
+
+	if err != nil {
+		log.Fatalf("Error beginning transaction (%s)\n", err)
+	}
+
+	return tx
+}
+
+func dbExec(tx *sql.Tx, query string, args ...interface{}) {
+	if OUTPUT != nil {
+		if err := OUTPUT.Exec(query, args...); err != nil {
+			log.Fatalf("Error writing script %s (%s)\n",
+				OUTPUT.ScriptPath, err,
+			)
+		}
+	} else if tx == nil {
+		if _, err := DB.Exec(query, args...); err != nil {
+			log.Fatalf("Query error: %s\nQuery: %s\n", err, query)
+		} else {
+			printQuery(query)
+		}
+	} else if _, err := tx.Exec(query, args...); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Fatalf("Error rolling back transaction\n"+
+				"Query error: %s\n"+
+				"Rollback error: %s\n"+
+				"Query: %s\n",
+				err, rbErr, query,
+			)
+		} else {
+			log.Fatalf("Query error: %s\nQuery: %s\n", err, query)
+		}
+	} else {
+		printQuery(query)
+	}
+}
+
+// Echoes a query at the debug log level.  It goes to the log (stderr), not
+// stdout, where -progress json writes its events.
+func printQuery(query string) {
+	if PRINT_SQL_QUERIES {
+		log.Println(query)
+	}
+}
+
+func dbCommit(tx *sql.Tx) {
+	if OUTPUT != nil {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Error committing transaction (%s)\n", err)
+	}
+}
+
+// Begins a load's transaction, which is rolled back if ctx is cancelled.
+// With -output it's a part of the script, written when it commits.
+func dbBeginLoad(ctx context.Context) (LoadTx, error) {
+	if OUTPUT != nil {
+		return OUTPUT.Begin(ctx), nil
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction (%s)", err)
+	}
+
+	return &DBTx{Tx: tx}, nil
+}
+
+// Runs a query in a load's transaction.  Unlike dbExec it returns errors,
+// leaving the caller to roll back and the load to cancel its other workers.
+func (tx *DBTx) Exec(query string, args ...interface{}) error {
+	if _, err := tx.Tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("query error: %s\nQuery: %s", err, query)
+	}
+	printQuery(query)
+
+	return nil
+}
+
+func (tx *DBTx) Commit() error {
+	return tx.Tx.Commit()
+}
+
+func (tx *DBTx) Rollback() error {
+	return tx.Tx.Rollback()
+}
+
+// Rolls back a failed load's transaction, which cancellation may already have
+// rolled back.
+func rollBackLoad(tx LoadTx) {
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		log.Printf("Error rolling back transaction (%s)\n", err)
+	}
+}
+
+func txPrepare(tx *sql.Tx, query string) (*sql.Stmt, error) {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %s\nQuery: %s", err, query)
+	}
+	printQuery(query)
+
+	return stmt, nil
+}
+
+func stmtExec(stmt *sql.Stmt, args ...interface{}) error {
+	if _, err := stmt.Exec(args...); err != nil {
+		return fmt.Errorf("statement error: %s\nValues: %v", err, args)
+	}
+
+	return nil
+}
+
+func stmtClose(stmt *sql.Stmt) error {
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("statement error: %s", err)
+	}
+
+	return nil
+}
+
+// Returns a table's name, qualified with the target schema if one is set.
+func tableIdentifier(tableName string) string {
+	return schemaTableIdentifier(SCHEMA, tableName)
+}
+
+func schemaTableIdentifier(schema string, tableName string) string {
+	if len(schema) == 0 {
+		return quoteIdentifier(tableName)
+	}
+
+	return quoteIdentifier(schema) + "." + quoteIdentifier(tableName)
+}
+
+// Quotes a table or column name built from the dictionary or a geographic
+// header layout.  Names are lowercased first so they match the unquoted names
+// the server queries use.
+func quoteIdentifier(name string) string {
+	return pq.QuoteIdentifier(strings.ToLower(name))
+}
+
+func quoteIdentifiers(names []string) string {
+	quotedNames := make([]string, len(names))
+	for ni, name := range names {
+		quotedNames[ni] = quoteIdentifier(name)
+	}
+
+	return strings.Join(quotedNames, ", ")
+}
+
+func NewTableWriter(tx LoadTx, tableName string, columnNames []string, numeric []bool) *TableWriter {
+	lowerColumnNames := make([]string, len(columnNames))
+	for ci, columnName := range columnNames {
+		lowerColumnNames[ci] = strings.ToLower(columnName)
+	}
+
+	tableWriter := &TableWriter{
+		Tx:          tx,
+		TableName:   strings.ToLower(tableName),
+		ColumnNames: lowerColumnNames,
+		Numeric:     numeric,
+		Method:      LOAD_METHOD,
+		StartTime:   time.Now(),
+	}
+	switch loadTx := tx.(type) {
+	case *DBTx:
+		tableWriter.dbTx = loadTx.Tx
+	case *script.Tx:
+		tableWriter.script = loadTx
+	}
+
+	return tableWriter
+}
+
+func (tw *TableWriter) WriteRow(values []string) error {
+	if len(values) != len(tw.ColumnNames) {
+		return fmt.Errorf("%s: got %d values for %d columns",
+			tw.TableName, len(values), len(tw.ColumnNames),
+		)
+	}
+	for vi, value := range values {
+		if !tw.Numeric[vi] || len(value) == 0 {
+			continue
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s: value %q for numeric column %s is not a "+
+				"number", tw.TableName, value, tw.ColumnNames[vi],
+			)
+		}
+	}
+
+	var err error
+	if tw.script != nil {
+		err = tw.scriptRow(values)
+	} else if tw.Method == "insert" {
+		err = tw.insertRow(values)
+	} else {
+		err = tw.copyRow(values)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", tw.TableName, err)
+	}
+	tw.RowCount++
+	if tw.Progress != nil {
+		tw.Progress.AddRows(1)
+	}
+
+	return nil
+}
+
+// Empty numeric values are written as NULL.
+func (tw *TableWriter) rowArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for vi, value := range values {
+		if tw.Numeric[vi] && len(value) == 0 {
+			args[vi] = nil
+		} else {
+			args[vi] = value
+		}
+	}
+
+	return args
+}
+
+func (tw *TableWriter) insertRow(values []string) error {
+	if tw.insertStatement == nil {
+		placeholders := make([]string, len(tw.ColumnNames))
+		for pi := range placeholders {
+			placeholders[pi] = fmt.Sprintf("$%d", pi+1)
+		}
+		stmt, err := txPrepare(tw.dbTx, fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			tableIdentifier(tw.TableName), quoteIdentifiers(tw.ColumnNames),
+			strings.Join(placeholders, ", "),
+		))
+		if err != nil {
+			return err
+		}
+		tw.insertStatement = stmt
+	}
+
+	return stmtExec(tw.insertStatement, tw.rowArgs(values)...)
+}
+
+func (tw *TableWriter) copyRow(values []string) error {
+	if tw.copyStatement == nil {
+		copyQuery := pq.CopyIn(tw.TableName, tw.ColumnNames...)
+		if len(SCHEMA) > 0 {
+			copyQuery = pq.CopyInSchema(SCHEMA, tw.TableName, tw.ColumnNames...)
+		}
+		stmt, err := txPrepare(tw.dbTx, copyQuery)
+		if err != nil {
+			return err
+		}
+		tw.copyStatement = stmt
+	}
+
+	if err := stmtExec(tw.copyStatement, tw.rowArgs(values)...); err != nil {
+		return err
+	}
+	tw.copyBatchRowCount++
+
+	if tw.copyBatchRowCount >= COPY_BATCH_SIZE {
+		return tw.flushCopy()
+	}
+
+	return nil
+}
+
+// Writes a row to the script's copy of the table, starting it with the first
+// row.
+func (tw *TableWriter) scriptRow(values []string) error {
+	if tw.RowCount == 0 {
+		err := tw.script.CopyIn(tableIdentifier(tw.TableName),
+			quoteIdentifiers(tw.ColumnNames), tw.TableName,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.script.WriteRow(tw.rowArgs(values))
+}
+
+func (tw *TableWriter) flushCopy() error {
+	if tw.copyStatement == nil {
+		return nil
+	}
+	if err := stmtExec(tw.copyStatement); err != nil {
+		return err
+	}
+	if err := stmtClose(tw.copyStatement); err != nil {
+		return err
+	}
+	tw.copyStatement = nil
+	tw.copyBatchRowCount = 0
+
+	return nil
+}
+
+// Flushes any buffered rows and logs the table's throughput.  The caller
+// still owns (and commits) the transaction.
+func (tw *TableWriter) Close() error {
+	if err := tw.flushCopy(); err != nil {
+		return fmt.Errorf("%s: %s", tw.TableName, err)
+	}
+	if tw.insertStatement != nil {
+		if err := stmtClose(tw.insertStatement); err != nil {
+			return fmt.Errorf("%s: %s", tw.TableName, err)
+		}
+		tw.insertStatement = nil
+	}
+	atomic.AddInt64(&TOTAL_ROWS_WRITTEN, int64(tw.RowCount))
+
+	elapsed := time.Since(tw.StartTime)
+	log.Printf("%s: wrote %d rows in %s (%.0f rows/sec, %s)\n",
+		tw.TableName, tw.RowCount, elapsed.Truncate(time.Millisecond),
+		rowsPerSecond(tw.RowCount, elapsed), tw.Method,
+	)
+
+	return nil
+}
+
+func rowsPerSecond(rowCount int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(rowCount) / elapsed.Seconds()
+}
+
+func createLoadManifest(tx *sql.Tx, schema string) {
+	dbExec(tx, "CREATE TABLE IF NOT EXISTS "+
+		schemaTableIdentifier(schema, "load_manifest")+" ("+
+		"table_name varchar(64) PRIMARY KEY, "+
+		"row_count bigint NOT NULL, "+
+		"source_checksums text NOT NULL, "+
+		"loaded_at timestamp with time zone NOT NULL DEFAULT now()"+
+		")",
+	)
+}
+
+// Reads which tables were fully committed by a previous run, and from which
+// source files.  With staging, tables a previous run already swapped in are
+// in the live schema's manifest instead of the staging schema's.
+func readLoadManifest() {
+	LOAD_MANIFEST = readManifest(SCHEMA)
+	if STAGING {
+		LIVE_MANIFEST = readManifest(LIVE_SCHEMA)
+	}
+}
+
+func readManifest(schema string) map[string]string {
+	manifest := make(map[string]string)
+
+	rows, err := DB.Query(
+		"SELECT table_name, source_checksums FROM " +
+			schemaTableIdentifier(schema, "load_manifest"),
+	)
+	if isUndefinedTable(err) {
+		return manifest
+	}
+	if err != nil {
+		log.Fatalf("Error reading load manifest (%s)\n", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, sourceChecksums string
+
+		if err := rows.Scan(&tableName, &sourceChecksums); err != nil {
+			log.Fatalf("Error reading load manifest (%s)\n", err)
+		}
+		manifest[tableName] = sourceChecksums
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("Error reading load manifest (%s)\n", err)
+	}
+
+	return manifest
+}
+
+// Returns true if err is PostgreSQL's error for a missing table.
+func isUndefinedTable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+
+	return ok && pqErr.Code == "42P01"
+}
+
+func sourceChecksums(dataFiles []CensusDataFile) (string, error) {
+	checksums := make([]string, len(dataFiles))
+	for fi, dataFile := range dataFiles {
+		checksum, err := dataFile.Checksum()
+		if err != nil {
+			return "", err
+		}
+		checksums[fi] = fmt.Sprintf("%s:%s", dataFile.File.Name, checksum)
+	}
+
+	return strings.Join(checksums, ","), nil
+}
+
+// Returns true if a previous run committed the table from the same source
+// files and this run is resuming.  With staging the table may be staged, or
+// already swapped into the live schema, where it's left as it is.  The
+// source files are only hashed if the manifest has the table.
+func tableIsLoaded(tableName string, checksums func() (string, error)) (bool, error) {
+	if !RESUME {
+		return false, nil
+	}
+	loadedChecksums, ok := LOAD_MANIFEST[tableName]
+	if !ok {
+		loadedChecksums, ok = LIVE_MANIFEST[tableName]
+	}
+	if !ok {
+		return false, nil
+	}
+	currentChecksums, err := checksums()
+	if err != nil {
+		return false, err
+	}
+
+	return loadedChecksums == currentChecksums, nil
+}
+
+// Records a table as fully loaded.  Called inside the table's transaction so
+// the manifest row commits (or rolls back) with the data.
+func recordTableLoaded(tx LoadTx, tableName string, rowCount int, checksums string) error {
+	err := tx.Exec(
+		"DELETE FROM "+tableIdentifier("load_manifest")+
+			" WHERE table_name = $1",
+		tableName,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(
+		"INSERT INTO "+tableIdentifier("load_manifest")+" "+
+			"(table_name, row_count, source_checksums) VALUES ($1, $2, $3)",
+		tableName, rowCount, checksums,
+	)
+}
+
+// Reads table descriptions and variable names and labels from the bundled
+// dictionary, or from the census API variables file given with -dictionary
+// in place of the bundled dictionary's.  A dictionary without variables is
+// an error, as SF1 tables would load without descriptions or labels.
+func GetAPIConcepts() (map[string]dictionary.Concept, error) {
+	vintage, err := strconv.Atoi(VINTAGE)
+	if err != nil {
+		return nil, fmt.Errorf("bad vintage %s (%s)", VINTAGE, err)
+	}
+	censusDictionary, err := dictionary.Load(PRODUCT, vintage)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(DICTIONARY_FILE) != 0 {
+		log.Printf("Reading census API variables from %s\n", DICTIONARY_FILE)
+		dictionaryFile, err := os.Open(DICTIONARY_FILE)
+		if err != nil {
+			return nil, fmt.Errorf("error opening dictionary file %s (%s)",
+				DICTIONARY_FILE, err,
+			)
+		}
+		defer dictionaryFile.Close()
+		if err := censusDictionary.ReadVariables(dictionaryFile); err != nil {
+			return nil, fmt.Errorf("error parsing dictionary file %s (%s)",
+				DICTIONARY_FILE, err,
+			)
+		}
+	} else {
+		log.Printf("Reading the bundled %s dictionary\n", censusDictionary)
+	}
+	if len(censusDictionary.Concepts) == 0 {
+		return nil, fmt.Errorf(
+			"the %s dictionary has no variables; give the census API "+
+				"variables file (sf1.xml) with -dictionary, or bundle it "+
+				"with convert_census_dictionary.go",
+			censusDictionary,
+		)
+	}
+
+	return censusDictionary.APIConcepts()
+}
+
+func GetGeoLocations(ctx context.Context, queue chan []string, tableProgress *progress.Table) error {
+	defer close(queue)
+
+	for _, state := range STATES {
+		var err error
+		if PRODUCT == "acs5" {
+			err = getStateACSGeoLocations(ctx, state, queue, tableProgress)
+		} else if PRODUCT == "pl2020" {
+			err = getStatePLGeoLocations(ctx, state, queue, tableProgress)
+		} else {
+			err = getStateGeoLocations(ctx, state, queue, tableProgress)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Queues a row for a table's writer, giving up if the load is cancelled.
+func queueRow(ctx context.Context, queue chan []string, values []string) error {
+	select {
+	case queue <- values:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func getStateGeoLocations(ctx context.Context, state string, queue chan []string, tableProgress *progress.Table) error {
+	geoFile := CENSUS_DATA_FILES[sf1.GeoFileName(state)]
+	geoReader, err := geoFile.Open()
+	if err != nil {
+		return err
+	}
+	defer geoReader.Close()
+	geoRecords := sf1.NewGeoReader(
+		tableProgress.Reader(geoReader), GEO_LAYOUT.Fields,
+	)
+	for {
+		geoRecord, err := geoRecords.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading geographic file %s (%s)",
+				geoFile.File.Path, err,
+			)
+		}
+		if err := queueRow(ctx, queue, geoRecord.Values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getStatePLGeoLocations(ctx context.Context, state string, queue chan []string, tableProgress *progress.Table) error {
+	geoFile := CENSUS_DATA_FILES[pl.GeoFileName(state)]
+	geoReader, err := geoFile.Open()
+	if err != nil {
+		return err
+	}
+	defer geoReader.Close()
+	geoRecords := pl.NewGeoReader(
+		tableProgress.Reader(geoReader), GEO_LAYOUT.Fields,
+	)
+	for {
+		geoRecord, err := geoRecords.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading geographic file %s (%s)",
+				geoFile.File.Path, err,
+			)
+		}
+		if err := queueRow(ctx, queue, geoRecord.Values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sends a state's ACS geography records without the layout's BLANK
+// columns.
+func getStateACSGeoLocations(ctx context.Context, state string, queue chan []string, tableProgress *progress.Table) error {
+	geoFile := CENSUS_DATA_FILES[acs.GeoFileName(VINTAGE, state)]
+	geoReader, err := geoFile.Open()
+	if err != nil {
+		return err
+	}
+	defer geoReader.Close()
+	geoRecords := acs.NewGeoReader(tableProgress.Reader(geoReader))
+	for {
+		geoRecord, err := geoRecords.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading geography file %s (%s)",
+				geoFile.File.Path, err,
+			)
+		}
+		values := []string{}
+		for fi, fieldName := range acs.GeoFields {
+			if len(fieldName) > 0 {
+				values = append(values, geoRecord.Values[fi])
+			}
+		}
+		if err := queueRow(ctx, queue, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetDataTables() []*CensusTable {
+	var api map[string]dictionary.Concept
+	dataTables := make(map[string]*CensusTable)
+	tableNames := []string{}
+
+	if PRODUCT == "sf1" {
+		var err error
+		api, err = GetAPIConcepts()
+		if err != nil {
+			log.Fatalf("Error reading dictionary (%s)\n", err)
+		}
+	}
+
+	for _, state := range STATES {
+		var stateDataTables []*CensusTable
+
+		if PRODUCT == "acs5" {
+			stateDataTables = getStateACSTables(state)
+		} else if PRODUCT == "pl2020" {
+			stateDataTables = getStatePLTables(state)
+		} else {
+			stateDataTables = getStateDataTables(state, api)
+		}
+		for _, dataTable := range stateDataTables {
+			existingTable, ok := dataTables[dataTable.Name]
+			if !ok {
+				dataTables[dataTable.Name] = dataTable
+				tableNames = append(tableNames, dataTable.Name)
+				continue
+			}
+			existingLocation := existingTable.DataLocations[0]
+			location := dataTable.DataLocations[0]
+			if len(dataTable.Columns) != len(existingTable.Columns) {
+				log.Fatalf(
+					"Table %s has %d columns in %s but %d in %s\n",
+					dataTable.Name,
+					len(existingTable.Columns), existingLocation.State,
+					len(dataTable.Columns), location.State,
+				)
+			}
+			existingTable.DataLocations = append(
+				existingTable.DataLocations, location,
+			)
+		}
+	}
+
+	orderedDataTables := make([]*CensusTable, len(tableNames))
+	for ti, tableName := range tableNames {
+		orderedDataTables[ti] = dataTables[tableName]
+	}
+
+	return orderedDataTables
+}
+
+func getStateDataTables(state string, api map[string]dictionary.Concept) []*CensusTable {
+	dataTables := []*CensusTable{}
+
+	packingListFile := CENSUS_DATA_FILES[sf1.PackingListFileName(state)].File
+	packingList, err := readPackingList(packingListFile)
+	if err != nil {
+		log.Fatalf("Error reading packing list file %s (%s)\n",
+			packingListFile.Path, err,
+		)
+	}
+
+	for _, packingListTable := range packingList.Tables {
+		dataTable := new(CensusTable)
+		tableName := packingListTable.Name
+		fileNumber := packingListTable.FileNumber
+		columnCount := packingListTable.ColumnCount
+		segmentFileName := sf1.DataFileName(state, fileNumber)
+		if _, present := CENSUS_DATA_FILES[segmentFileName]; !present {
+			log.Fatalf(
+				"Census data file %s not recognized "+
+					"(built from file number %d for table %s)\n",
+				segmentFileName, fileNumber, tableName,
+			)
+		}
+		apiConcept, ok := api[tableName]
+		if !ok {
+			log.Fatalf("Dictionary lacks table %s\n", tableName)
+		}
+		dataTable.DataLocations = append(
+			dataTable.DataLocations, CensusDataLocation{
+				State:    state,
+				DataFile: CENSUS_DATA_FILES[segmentFileName],
+				Table:    packingListTable,
+			},
+		)
+		dataTable.Name = tableName
+		dataTable.Description = apiConcept.Description
+		dataTable.RowCount = 0
+		dataTable.KeyColumnCount = sf1.SegmentKeyColumns
+		dataTable.KeyColumnDefinitions = SF1_KEY_COLUMN_DEFINITIONS
+		dataTable.ColumnType = "integer"
+		dataTable.Columns = append(
+			dataTable.Columns, CensusColumn{Name: "fileid"},
+		)
+		dataTable.Columns = append(
+			dataTable.Columns, CensusColumn{Name: "stusab"},
+		)
+		dataTable.Columns = append(
+			dataTable.Columns, CensusColumn{Name: "chariter"},
+		)
+		dataTable.Columns = append(
+			dataTable.Columns, CensusColumn{Name: "cifsn"},
+		)
+		dataTable.Columns = append(
+			dataTable.Columns, CensusColumn{Name: "logrecno"},
+		)
+		for _, apiVariable := range apiConcept.Variables {
+			dataTable.Columns = append(dataTable.Columns, CensusColumn{
+				Name:  apiVariable.Name,
+				Label: apiVariable.Description,
+			})
+		}
+
+		if len(dataTable.Columns)-5 != columnCount {
+			log.Fatalf("Found column count mismatch in table %s (%d != %d)\n",
+				dataTable.Name, len(dataTable.Columns), columnCount,
+			)
+		}
+		dataTables = append(dataTables, dataTable)
+	}
+
+	return dataTables
+}
+
+// Builds a state's tables from the redistricting data's fixed layout.
+// Variable names follow the census' scheme, as without a dictionary.
+func getStatePLTables(state string) []*CensusTable {
+	dataTables := []*CensusTable{}
+
+	for _, plTable := range pl.Tables {
+		apiConcept, err := dictionary.GenerateConcept(plTable.Name, plTable.ColumnCount)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		dataTable := &CensusTable{
+			DataLocations: []CensusDataLocation{{
+				State: state,
+				DataFile: CENSUS_DATA_FILES[pl.DataFileName(
+					state, plTable.FileNumber,
+				)],
+				Table: plTable,
+			}},
+			Name:                 plTable.Name,
+			Description:          pl.TableDescriptions[plTable.Name].Title,
+			Universe:             pl.TableDescriptions[plTable.Name].Universe,
+			KeyColumnCount:       sf1.SegmentKeyColumns,
+			KeyColumnDefinitions: SF1_KEY_COLUMN_DEFINITIONS,
+			ColumnType:           "integer",
+		}
+		for _, columnName := range sf1.SegmentKeyColumnNames {
+			dataTable.Columns = append(
+				dataTable.Columns, CensusColumn{Name: columnName},
+			)
+		}
+		for _, apiVariable := range apiConcept.Variables {
+			dataTable.Columns = append(dataTable.Columns, CensusColumn{
+				Name:  apiVariable.Name,
+				Label: apiVariable.Description,
+			})
+		}
+		dataTables = append(dataTables, dataTable)
+	}
+
+	return dataTables
+}
+
+// Builds a state's tables from the ACS lookup file.  Each cell gets an
+// estimate column and a margin of error column.
+func getStateACSTables(state string) []*CensusTable {
+	dataTables := []*CensusTable{}
+
+	for _, acsTable := range ACS_LOOKUP.Tables {
+		if len(acsTable.Lines) == 0 {
+			continue
+		}
+
+		location := CensusDataLocation{State: state, ACSTable: acsTable}
+		for _, part := range acsTable.Parts {
+			location.EstimateFiles = append(location.EstimateFiles,
+				CENSUS_DATA_FILES[acs.EstimateFileName(
+					VINTAGE, state, part.Sequence,
+				)],
+			)
+			location.MOEFiles = append(location.MOEFiles,
+				CENSUS_DATA_FILES[acs.MOEFileName(
+					VINTAGE, state, part.Sequence,
+				)],
+			)
+		}
+
+		dataTable := &CensusTable{
+			DataLocations:        []CensusDataLocation{location},
+			Name:                 acsTable.Name,
+			Description:          acsTable.Title,
+			Universe:             acsTable.Universe,
+			KeyColumnCount:       acs.SequenceKeyColumns,
+			KeyColumnDefinitions: ACS_KEY_COLUMN_DEFINITIONS,
+			ColumnType:           "numeric",
+		}
+		columnLabels := acs.ColumnLabels(acsTable)
+		for ci, columnName := range acs.ColumnNames(acsTable) {
+			dataTable.Columns = append(dataTable.Columns, CensusColumn{
+				Name:  columnName,
+				Label: columnLabels[ci],
+			})
+		}
+		dataTables = append(dataTables, dataTable)
+	}
+
+	return dataTables
+}
+
+// Returns the geo_locations column definitions and names, and which columns
+// are numeric, for the product being loaded.
+func geoLocationColumns() ([]string, []string, []bool) {
+	columnDefinitions := []string{}
+	columnNames := []string{}
+	columnNumeric := []bool{}
+
+	if PRODUCT == "acs5" {
+		for _, fieldName := range acs.GeoFields {
+			if len(fieldName) == 0 {
+				continue
+			}
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s varchar", quoteIdentifier(fieldName),
+			))
+			columnNames = append(columnNames, fieldName)
+			columnNumeric = append(columnNumeric, false)
+		}
+
+		return columnDefinitions, columnNames, columnNumeric
+	}
+
+	for _, field := range GEO_LAYOUT.Fields {
+		numeric := field.Numeric
+		if field.ReferenceName == "AREALAND" ||
+			field.ReferenceName == "AREAWATR" {
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s bigint", quoteIdentifier(field.ReferenceName),
+			))
+		} else if field.ReferenceName == "INTPTLAT" ||
+			field.ReferenceName == "INTPTLON" {
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s numeric", quoteIdentifier(field.ReferenceName),
+			))
+			numeric = true
+		} else if field.Numeric {
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s integer", quoteIdentifier(field.ReferenceName),
+			))
+		} else if field.Size > 0 {
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s varchar (%d)",
+				quoteIdentifier(field.ReferenceName), field.Size,
+			))
+		} else {
+			columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+				"%s varchar", quoteIdentifier(field.ReferenceName),
+			))
+		}
+		columnNames = append(columnNames, field.ReferenceName)
+		columnNumeric = append(columnNumeric, numeric)
+	}
+	if _, _, ok := geoPointFields(); ok {
+		columnDefinitions = append(columnDefinitions, fmt.Sprintf(
+			"geom geometry(Point, %d)", tiger.SRID_NAD83,
+		))
+		columnNames = append(columnNames, "geom")
+		columnNumeric = append(columnNumeric, false)
+	}
+
+	return columnDefinitions, columnNames, columnNumeric
+}
+
+// Returns the indices of the internal point fields in the geographic header
+// layout, if it has them.
+func geoPointFields() (int, int, bool) {
+	latIndex, lonIndex := -1, -1
+	if GEO_LAYOUT == nil {
+		return latIndex, lonIndex, false
+	}
+	for fi, field := range GEO_LAYOUT.Fields {
+		if field.ReferenceName == "INTPTLAT" {
+			latIndex = fi
+		} else if field.ReferenceName == "INTPTLON" {
+			lonIndex = fi
+		}
+	}
+
+	return latIndex, lonIndex, latIndex >= 0 && lonIndex >= 0
+}
+
+// Rewrites a geographic record's internal point as plain decimals and
+// appends it as a point in EWKT.  Records without an internal point get an
+// empty point.
+func geoPointValues(values []string, latIndex int, lonIndex int) ([]string, error) {
+	point := fmt.Sprintf("SRID=%d;POINT EMPTY", tiger.SRID_NAD83)
+
+	if len(values[latIndex]) > 0 || len(values[lonIndex]) > 0 {
+		lat, err := sf1.ParseCoordinate(values[latIndex])
+		if err != nil {
+			return nil, err
+		}
+		lon, err := sf1.ParseCoordinate(values[lonIndex])
+		if err != nil {
+			return nil, err
+		}
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("internal point (%s, %s) out of range",
+				values[latIndex], values[lonIndex],
+			)
+		}
+		values[latIndex] = strconv.FormatFloat(lat, 'f', -1, 64)
+		values[lonIndex] = strconv.FormatFloat(lon, 'f', -1, 64)
+		point = fmt.Sprintf("SRID=%d;POINT(%s %s)",
+			tiger.SRID_NAD83, values[lonIndex], values[latIndex],
+		)
+	}
+
+	return append(values, point), nil
+}
+
+func loadGeoLocationData(ctx context.Context) error {
+	geoFiles := make([]CensusDataFile, len(STATES))
+	for si, state := range STATES {
+		geoFiles[si] = CENSUS_DATA_FILES[geoFileName(state)]
+	}
+	loaded, err := tableIsLoaded("geo_locations", func() (string, error) {
+		return sourceChecksums(geoFiles)
+	})
+	if err != nil {
+		return err
+	}
+	if loaded {
+		log.Println("Skipping geographic location data, already loaded")
+		return nil
+	}
+
+	log.Println("Loading geographic location data")
+	geoFilesSize := int64(0)
+	for _, geoFile := range geoFiles {
+		geoFilesSize += geoFile.File.Size
+	}
+	tableProgress := PROGRESS.Add("geo_locations", geoFilesSize, 0)
+	PROGRESS.Start(tableProgress)
+
+	tx, err := dbBeginLoad(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeGeoLocations(ctx, tx, tableProgress, geoFiles); err != nil {
+		rollBackLoad(tx)
+		return fmt.Errorf("geo_locations: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing geo_locations (%s)", err)
+	}
+	PROGRESS.Finish(tableProgress)
+	log.Println("Geographic location data loaded")
+
+	return nil
+}
+
+// Creates and fills geo_locations inside the load's transaction, reading the
+// geographic files in one goroutine while writing rows in another.
+func writeGeoLocations(ctx context.Context, tx LoadTx, tableProgress *progress.Table, geoFiles []CensusDataFile) error {
+	columnDefinitions, columnNames, columnNumeric := geoLocationColumns()
+	dropGeoLocationsTableQuery := "DROP TABLE IF EXISTS " +
+		tableIdentifier("geo_locations")
+	createGeoLocationsTableQuery := fmt.Sprintf(
+		"CREATE TABLE %s (id SERIAL PRIMARY KEY, %s)",
+		tableIdentifier("geo_locations"), strings.Join(columnDefinitions, ", "),
+	)
+
+	if err := tx.Exec(dropGeoLocationsTableQuery); err != nil {
+		return err
+	}
+	if err := tx.Exec(createGeoLocationsTableQuery); err != nil {
+		return err
+	}
+	log.Println("Created table 'geo_locations'")
+
+	tableWriter := NewTableWriter(
+		tx, "geo_locations", columnNames, columnNumeric,
+	)
+	tableWriter.Progress = tableProgress
+	latIndex, lonIndex, hasPoints := geoPointFields()
+
+	geoLoad := NewLoadGroup(ctx)
+	geoLocationQueue := make(chan []string, 10)
+	geoLoad.Go(func(ctx context.Context) error {
+		return GetGeoLocations(ctx, geoLocationQueue, tableProgress)
+	})
+	geoLoad.Go(func(ctx context.Context) error {
+		for values := range geoLocationQueue {
+			if hasPoints {
+				pointValues, err := geoPointValues(values, latIndex, lonIndex)
+				if err != nil {
+					return err
+				}
+				values = pointValues
+			}
+			if err := tableWriter.WriteRow(values); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := geoLoad.Wait(); err != nil {
+		return err
+	}
+	if err := tableWriter.Close(); err != nil {
+		return err
+	}
+	checksums, err := sourceChecksums(geoFiles)
+	if err != nil {
+		return err
+	}
+
+	return recordTableLoaded(
+		tx, "geo_locations", tableWriter.RowCount, checksums,
+	)
+}
+
+func pathChecksums(filePaths []string) (string, error) {
+	checksums := make([]string, len(filePaths))
+	for fi, filePath := range filePaths {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return "", fmt.Errorf("error opening %s (%s)", filePath, err)
+		}
+		checksum, err := readerChecksum(filePath, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+		checksums[fi] = fmt.Sprintf("%s:%s", path.Base(filePath), checksum)
+	}
+
+	return strings.Join(checksums, ","), nil
+}
+
+// Creates the tabblock table of block geometries from TIGER/Line tabblock
+// shapefiles, in place of running shp2pgsql by hand.
+func loadTabblockData(ctx context.Context) error {
+	loaded, err := tableIsLoaded("tabblock", func() (string, error) {
+		return pathChecksums(TABBLOCK_FILES)
+	})
+	if err != nil {
+		return err
+	}
+	if loaded {
+		log.Println("Skipping block geometries, already loaded")
+		return nil
+	}
+
+	log.Println("Loading block geometries")
+	recordCount, err := tabblockRecordCount()
+	if err != nil {
+		return err
+	}
+	tableProgress := PROGRESS.Add("tabblock", 0, recordCount)
+	PROGRESS.Start(tableProgress)
+
+	tx, err := dbBeginLoad(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeTabblocks(tx, tableProgress); err != nil {
+		rollBackLoad(tx)
+		return fmt.Errorf("tabblock: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing tabblock (%s)", err)
+	}
+	PROGRESS.Finish(tableProgress)
+	log.Println("Block geometries loaded")
+
+	return nil
+}
+
+// Creates, fills and indexes tabblock inside the load's transaction.  Once
+// the load is cancelled the transaction is rolled back, so the next write
+// fails and stops the shapefile being read.
+func writeTabblocks(tx LoadTx, tableProgress *progress.Table) error {
+	err := tx.Exec("DROP TABLE IF EXISTS " + tableIdentifier("tabblock"))
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(fmt.Sprintf(
+		"CREATE TABLE %s ("+
+			"tabblock_id varchar(16), statefp varchar(2), "+
+			"countyfp varchar(3), tractce varchar(6), blockce varchar(4), "+
+			"name varchar(20), intptlat varchar(11), intptlon varchar(12), "+
+			"the_geom geometry(MultiPolygon, %d)"+
+			")", tableIdentifier("tabblock"), tiger.SRID_NAD83,
+	))
+	if err != nil {
+		return err
+	}
+	log.Println("Created table 'tabblock'")
+
+	tableWriter := NewTableWriter(tx, "tabblock",
+		[]string{
+			"tabblock_id", "statefp", "countyfp", "tractce", "blockce",
+			"name", "intptlat", "intptlon", "the_geom",
+		},
+		make([]bool, 9),
+	)
+	tableWriter.Progress = tableProgress
+	for _, tabblockFile := range TABBLOCK_FILES {
+		if err := loadTabblockFile(tableWriter, tabblockFile); err != nil {
+			return err
+		}
+	}
+	if err := tableWriter.Close(); err != nil {
+		return err
+	}
+
+	err = tx.Exec(
+		"ALTER TABLE " + tableIdentifier("tabblock") +
+			" ADD PRIMARY KEY (tabblock_id)",
+	)
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(
+		"CREATE INDEX idx_tabblock_the_geom ON " +
+			tableIdentifier("tabblock") + " USING gist (the_geom)",
+	)
+	if err != nil {
+		return err
+	}
+
+	checksums, err := pathChecksums(TABBLOCK_FILES)
+	if err != nil {
+		return err
+	}
+
+	return recordTableLoaded(tx, "tabblock", tableWriter.RowCount, checksums)
+}
+
+// Counts the blocks in every tabblock file up front, so progress has a total
+// to estimate against.
+func tabblockRecordCount() (int64, error) {
+	recordCount := int64(0)
+	for _, tabblockFile := range TABBLOCK_FILES {
+		reader, err := tiger.Open(tabblockFile)
+		if err != nil {
+			return 0, fmt.Errorf("error opening shapefile (%s)", err)
+		}
+		recordCount += int64(reader.RecordCount())
+		reader.Close()
+	}
+
+	return recordCount, nil
+}
+
+func loadTabblockFile(tableWriter *TableWriter, tabblockFile string) error {
+	reader, err := tiger.Open(tabblockFile)
+	if err != nil {
+		return fmt.Errorf("error opening shapefile (%s)", err)
+	}
+	defer reader.Close()
+	if reader.SRID != tiger.SRID_NAD83 {
+		return fmt.Errorf("%s: expected SRID %d, got %d",
+			tabblockFile, tiger.SRID_NAD83, reader.SRID,
+		)
+	}
+
+	vintage, err := strconv.Atoi(VINTAGE)
+	if err != nil {
+		return err
+	}
+
+	rowCount := 0
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading shapefile (%s)", err)
+		}
+
+		// Field names carry the vintage, e.g. GEOID10 or GEOID20
+		attribute := func(name string) string {
+			return record.VintageAttribute(name, vintage)
+		}
+		blockID := attribute("GEOID")
+		if len(blockID) == 0 {
+			blockID = attribute("BLKIDFP")
+		}
+		if len(blockID) == 0 {
+			blockID = attribute("STATEFP") + attribute("COUNTYFP") +
+				attribute("TRACTCE") + attribute("BLOCKCE")
+		}
+		if len(blockID) != TABBLOCK_ID_LENGTH {
+			return fmt.Errorf("%s: record %d has no %d-digit block ID "+
+				"(got %q)", tabblockFile, record.Number, TABBLOCK_ID_LENGTH,
+				blockID,
+			)
+		}
+		err = tableWriter.WriteRow([]string{
+			blockID,
+			attribute("STATEFP"),
+			attribute("COUNTYFP"),
+			attribute("TRACTCE"),
+			attribute("BLOCKCE"),
+			attribute("NAME"),
+			attribute("INTPTLAT"),
+			attribute("INTPTLON"),
+			reader.HexEWKB(record),
+		})
+		if err != nil {
+			return err
+		}
+		rowCount++
+	}
+	log.Printf("tabblock: read %d blocks from %s\n", rowCount, tabblockFile)
+
+	return nil
+}
+
+// Writes census_tables and census_variables, describing each selected table
+// and its columns, so analysts can look up what a column like p0190009 holds
+// without the technical documentation.  They're small, so they're rewritten
+// every run, resuming or not.
+func loadCensusMetadata(ctx context.Context, dataTables []*CensusTable) error {
+	tx, err := dbBeginLoad(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeCensusMetadata(tx, dataTables); err != nil {
+		rollBackLoad(tx)
+		return fmt.Errorf("census metadata: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing census metadata (%s)", err)
+	}
+	log.Println("Census metadata loaded")
+
+	return nil
+}
+
+func writeCensusMetadata(tx LoadTx, dataTables []*CensusTable) error {
+	queries := []string{
+		"DROP TABLE IF EXISTS " + tableIdentifier("census_variables"),
+		"DROP TABLE IF EXISTS " + tableIdentifier("census_tables"),
+		"CREATE TABLE " + tableIdentifier("census_tables") + " (" +
+			"table_name varchar(64) PRIMARY KEY, description text, " +
+			"universe text, segments integer[]" +
+			")",
+		"CREATE TABLE " + tableIdentifier("census_variables") + " (" +
+			"table_name varchar(64), variable_name varchar(64), " +
+			"position integer, label text, " +
+			"PRIMARY KEY (table_name, variable_name)" +
+			")",
+	}
+	for _, query := range queries {
+		if err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	log.Println("Created tables 'census_tables' and 'census_variables'")
+
+	tablesWriter := NewTableWriter(tx, "census_tables",
+		[]string{"table_name", "description", "universe", "segments"},
+		make([]bool, 4),
+	)
+	for _, dataTable := range dataTables {
+		err := tablesWriter.WriteRow([]string{
+			dataTable.Name, dataTable.Description, dataTable.Universe,
+			dataTable.segments(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tablesWriter.Close(); err != nil {
+		return err
+	}
+
+	variablesWriter := NewTableWriter(tx, "census_variables",
+		[]string{"table_name", "variable_name", "position", "label"},
+		[]bool{false, false, true, false},
+	)
+	for _, dataTable := range dataTables {
+		dataColumns := dataTable.Columns[dataTable.KeyColumnCount:]
+		for ci, column := range dataColumns {
+			err := variablesWriter.WriteRow([]string{
+				dataTable.Name, strings.ToLower(column.Name),
+				strconv.Itoa(ci + 1), column.Label,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := variablesWriter.Close(); err != nil {
+		return err
+	}
+
+	err := recordTableLoaded(
+		tx, "census_tables", tablesWriter.RowCount, "",
+	)
+	if err != nil {
+		return err
+	}
+
+	return recordTableLoaded(
+		tx, "census_variables", variablesWriter.RowCount, "",
+	)
+}
+
+// Returns the numbers of the data segment (or, for ACS tables, sequence)
+// files a table is read from, as a PostgreSQL array.
+func (dataTable *CensusTable) segments() string {
+	numbers := []string{}
+	dataLocation := dataTable.DataLocations[0]
+	if dataLocation.ACSTable != nil {
+		for _, part := range dataLocation.ACSTable.Parts {
+			numbers = append(numbers, strconv.Itoa(part.Sequence))
+		}
+	} else {
+		numbers = append(numbers, strconv.Itoa(dataLocation.Table.FileNumber))
+	}
+
+	return "{" + strings.Join(numbers, ",") + "}"
+}
+
+// Returns the selected tables, and those of them to load: all but, when
+// resuming, those already loaded.
+func selectCensusTables() ([]*CensusTable, []*CensusTable) {
+	selectedTables := []*CensusTable{}
+	dataTables := []*CensusTable{}
+	skippedTableCount := 0
+	for _, dataTable := range GetDataTables() {
+		if !TABLE_SELECTION.Selects(dataTable.Name) {
+			UNSELECTED_TABLES = append(UNSELECTED_TABLES, dataTable.Name)
+			continue
+		}
+		SELECTED_TABLES = append(SELECTED_TABLES, dataTable.Name)
+		selectedTables = append(selectedTables, dataTable)
+		loaded, err := tableIsLoaded(dataTable.Name,
+			func() (string, error) { return dataTableChecksums(dataTable) },
+		)
+		if err != nil {
+			log.Fatalf("Error checking table %s (%s)\n", dataTable.Name, err)
+		}
+		if loaded {
+			skippedTableCount++
+			continue
+		}
+		dataTableSize := int64(0)
+		for _, dataLocation := range dataTable.DataLocations {
+			for _, dataFile := range dataLocation.dataFiles() {
+				dataTableSize += dataFile.File.Size
+			}
+		}
+		dataTable.Progress = PROGRESS.Add(dataTable.Name, dataTableSize, 0)
+		dataTables = append(dataTables, dataTable)
+	}
+	if skippedTableCount > 0 {
+		log.Printf("Skipped %d tables already loaded\n", skippedTableCount)
+	}
+
+	return selectedTables, dataTables
+}
+
+// Starts WORKER_COUNT workers in the load group, each loading one segment at
+// a time.  Segments are handed out one by one, so a failed load stops
+// handing them out.
+func loadCensusData(loads *LoadGroup, dataTables []*CensusTable) {
+	pool := NewConnectionPool(connectionPoolSize(len(dataTables)))
+	segments := groupCensusSegments(dataTables, pool.Size)
+	segmentQueue := make(chan *CensusSegment)
+	tablesLeft := int64(len(dataTables))
+
+	loads.Go(func(ctx context.Context) error {
+		defer close(segmentQueue)
+		for _, segment := range segments {
+			select {
+			case segmentQueue <- segment:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	for wi := 0; wi < WORKER_COUNT; wi++ {
+		loads.Go(func(ctx context.Context) error {
+			for segment := range segmentQueue {
+				if err := loadCensusSegment(ctx, segment, pool); err != nil {
+					return err
+				}
+				for _, dataTable := range segment.Tables {
+					log.Printf("Loaded table %s, %d to go\n",
+						dataTable.Name, atomic.AddInt64(&tablesLeft, -1),
+					)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// The connections census tables can use: whatever the database pool allows
+// after the geographic location, block geometry and metadata loaders take
+// theirs, or one per table if it's unlimited.
+func connectionPoolSize(tableCount int) int {
+	if CONFIG.MaxOpenConns == 0 {
+		if tableCount < 1 {
+			return 1
+		}
+		return tableCount
+	}
+	if CONFIG.MaxOpenConns < 4 {
+		log.Fatalf("The loader needs at least 4 database connections, "+
+			"max-open-conns is %d\n", CONFIG.MaxOpenConns,
+		)
+	}
+
+	return CONFIG.MaxOpenConns - 3
+}
+
+func NewConnectionPool(size int) *ConnectionPool {
+	cp := &ConnectionPool{Size: size, tokens: make(chan struct{}, size)}
+	cp.Release(size)
+
+	return cp
+}
+
+// Waits for count connections, giving back those already taken if ctx is
+// cancelled first.
+func (cp *ConnectionPool) Acquire(ctx context.Context, count int) error {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	for ci := 0; ci < count; ci++ {
+		select {
+		case <-cp.tokens:
+		case <-ctx.Done():
+			cp.Release(ci)
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (cp *ConnectionPool) Release(count int) {
+	for ci := 0; ci < count; ci++ {
+		cp.tokens <- struct{}{}
+	}
+}
+
+func NewLoadGroup(ctx context.Context) *LoadGroup {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &LoadGroup{ctx: ctx, cancel: cancel}
+}
+
+// Runs worker in its own goroutine.  The first worker to fail cancels the
+// context every worker is given.
+func (lg *LoadGroup) Go(worker func(ctx context.Context) error) {
+	lg.wg.Add(1)
+	go func() {
+		defer lg.wg.Done()
+		if err := worker(lg.ctx); err != nil {
+			lg.once.Do(func() {
+				lg.err = err
+				lg.cancel()
+			})
+		}
+	}()
+}
+
+// Waits for every worker to return, then returns the first error.
+func (lg *LoadGroup) Wait() error {
+	lg.wg.Wait()
+	lg.cancel()
+
+	return lg.err
+}
+
+// Groups tables by the segment files they're read from, in the order they
+// were listed.  A segment holding more tables than there are connections is
+// split, and read once per part.
+func groupCensusSegments(dataTables []*CensusTable, maxTables int) []*CensusSegment {
+	segments := []*CensusSegment{}
+	segmentsByFiles := make(map[string]*CensusSegment)
+
+	for _, dataTable := range dataTables {
+		if dataTable.DataLocations[0].ACSTable != nil {
+			segments = append(segments,
+				&CensusSegment{Tables: []*CensusTable{dataTable}},
+			)
+			continue
+		}
+
+		files := []CensusDataFile{}
+		filePaths := []string{}
+		for _, dataLocation := range dataTable.DataLocations {
+			files = append(files, dataLocation.DataFile)
+			filePaths = append(filePaths, dataLocation.DataFile.File.Path)
+		}
+		key := strings.Join(filePaths, ",")
+		segment, ok := segmentsByFiles[key]
+		if !ok || len(segment.Tables) >= maxTables {
+			segment = &CensusSegment{Files: files}
+			segmentsByFiles[key] = segment
+			segments = append(segments, segment)
+		}
+		segment.Tables = append(segment.Tables, dataTable)
+	}
+
+	return segments
+}
+
+// Loads a segment's tables, reading each of its files once and queueing
+// every row's columns to each table's writer.  The queues are short, so a
+// slow table holds the reader back rather than rows piling up in memory.
+func loadCensusSegment(ctx context.Context, segment *CensusSegment, pool *ConnectionPool) error {
+	if err := pool.Acquire(ctx, len(segment.Tables)); err != nil {
+		return err
+	}
+	defer pool.Release(len(segment.Tables))
+
+	if len(segment.Files) == 0 {
+		return loadCensusDataTable(ctx, segment.Tables[0])
+	}
+
+	tableWriters := make([]*TableWriter, len(segment.Tables))
+	for ti, dataTable := range segment.Tables {
+		log.Printf("Loading census data table %s\n", dataTable.Name)
+		tableWriter, err := createCensusDataTable(ctx, dataTable)
+		if err != nil {
+			for _, tableWriter := range tableWriters[:ti] {
+				rollBackLoad(tableWriter.Tx)
+			}
+			return err
+		}
+		tableWriters[ti] = tableWriter
+	}
+
+	// The reader and the writers stop together: a failed writer cancels
+	// the reader, which closes the queues, and the other writers then roll
+	// back rather than commit a partial table.
+	segmentLoad := NewLoadGroup(ctx)
+	rowQueues := make([]chan []string, len(segment.Tables))
+	for ti, dataTable := range segment.Tables {
+		dataTable := dataTable
+		tableWriter := tableWriters[ti]
+		rowQueue := make(chan []string, SEGMENT_QUEUE_SIZE)
+		rowQueues[ti] = rowQueue
+
+		segmentLoad.Go(func(ctx context.Context) error {
+			for values := range rowQueue {
+				if err := tableWriter.WriteRow(values); err != nil {
+					rollBackLoad(tableWriter.Tx)
+					return err
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				rollBackLoad(tableWriter.Tx)
+				return err
+			}
+			err := finishCensusDataTable(dataTable, tableWriter)
+			if err != nil {
+				rollBackLoad(tableWriter.Tx)
+			}
+			return err
+		})
+	}
+	segmentLoad.Go(func(ctx context.Context) error {
+		defer func() {
+			for _, rowQueue := range rowQueues {
+				close(rowQueue)
+			}
+		}()
+		for fi := range segment.Files {
+			err := readCensusSegmentFile(ctx, segment, fi, rowQueues)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return segmentLoad.Wait()
+}
+
+func readCensusSegmentFile(ctx context.Context, segment *CensusSegment, fileIndex int, rowQueues []chan []string) error {
+	dataFile := segment.Files[fileIndex]
+	dataReader, err := dataFile.Open()
+	if err != nil {
+		return err
+	}
+	defer dataReader.Close()
+
+	// Every table counts the bytes read towards its progress
+	var countingReader io.Reader = dataReader
+	for _, dataTable := range segment.Tables {
+		countingReader = dataTable.Progress.Reader(countingReader)
+	}
+	segmentReader := sf1.NewDelimitedSegmentReader(
+		countingReader, segmentDelimiter(),
+	)
+
+	rowCount := 0
+	for {
+		row, err := segmentReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading file %s (%s)",
+				dataFile.File.Path, err,
+			)
+		}
+		for ti, dataTable := range segment.Tables {
+			values, err := row.TableValues(
+				dataTable.DataLocations[fileIndex].Table,
+			)
+			if err != nil {
+				return fmt.Errorf("error reading %s from file %s (%s)",
+					dataTable.Name, dataFile.File.Path, err,
+				)
+			}
+			if err := queueRow(ctx, rowQueues[ti], values); err != nil {
+				return err
+			}
+			dataTable.RowCount++
+		}
+		rowCount++
+	}
+	log.Printf("%s: read %d rows for %d tables\n",
+		dataFile.File.Name, rowCount, len(segment.Tables),
+	)
+
+	return nil
+}
+
+// Returns the files a table's data is read from in one state.
+func (dataLocation CensusDataLocation) dataFiles() []CensusDataFile {
+	if dataLocation.ACSTable != nil {
+		return append(
+			append([]CensusDataFile{}, dataLocation.EstimateFiles...),
+			dataLocation.MOEFiles...,
+		)
+	}
+
+	return []CensusDataFile{dataLocation.DataFile}
+}
+
+func dataTableChecksums(dataTable *CensusTable) (string, error) {
+	dataFiles := []CensusDataFile{}
+	for _, dataLocation := range dataTable.DataLocations {
+		dataFiles = append(dataFiles, dataLocation.dataFiles()...)
+	}
+
+	return sourceChecksums(dataFiles)
+}
+
+// Loads an ACS table on its own, reading its estimate and margin of error
+// files in each state.
+func loadCensusDataTable(ctx context.Context, dataTable *CensusTable) error {
+	log.Printf("Loading census data table %s\n", dataTable.Name)
+	tableWriter, err := createCensusDataTable(ctx, dataTable)
+	if err != nil {
+		return err
+	}
+	for _, dataLocation := range dataTable.DataLocations {
+		rowCount, err := loadACSDataLocation(
+			tableWriter, dataTable, dataLocation,
+		)
+		if err != nil {
+			rollBackLoad(tableWriter.Tx)
+			return err
+		}
+		dataTable.RowCount += rowCount
+	}
+	if err := finishCensusDataTable(dataTable, tableWriter); err != nil {
+		rollBackLoad(tableWriter.Tx)
+		return err
+	}
+
+	return nil
+}
+
+// Creates a table in its own transaction, returning the writer its rows go
+// through.  The transaction is rolled back if ctx is cancelled.
+func createCensusDataTable(ctx context.Context, dataTable *CensusTable) (*TableWriter, error) {
+	keyColumnCount := dataTable.KeyColumnCount
+	columnDefinitionSlice := make(
+		[]string, len(dataTable.Columns)-keyColumnCount,
+	)
+	for ci, column := range dataTable.Columns {
+		if ci < keyColumnCount { // skip the geographic location columns
+			continue
+		}
+		columnDefinitionSlice[ci-keyColumnCount] = fmt.Sprintf(
+			"%s %s", quoteIdentifier(column.Name), dataTable.ColumnType,
+		)
+	}
+	columnDefinitions := strings.Join(columnDefinitionSlice, ", ")
+	dropDataTableQuery := fmt.Sprintf(
+		"DROP TABLE IF EXISTS %s", tableIdentifier(dataTable.Name),
+	)
+	createDataTableQuery := fmt.Sprintf(
+		"CREATE TABLE %s (id SERIAL PRIMARY KEY, %s, %s)",
+		tableIdentifier(dataTable.Name), dataTable.KeyColumnDefinitions,
+		columnDefinitions,
+	)
+
+	PROGRESS.Start(dataTable.Progress)
+	tx, err := dbBeginLoad(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, query := range []string{dropDataTableQuery, createDataTableQuery} {
+		if err := tx.Exec(query); err != nil {
+			rollBackLoad(tx)
+			return nil, fmt.Errorf("%s: %s", dataTable.Name, err)
+		}
+	}
+	log.Printf("Created table '%s'\n", dataTable.Name)
+
+	columnNames := make([]string, len(dataTable.Columns))
+	columnNumeric := make([]bool, len(dataTable.Columns))
+	for ci, column := range dataTable.Columns {
+		columnNames[ci] = column.Name
+		columnNumeric[ci] = ci >= keyColumnCount
+	}
+
+	tableWriter := NewTableWriter(
+		tx, dataTable.Name, columnNames, columnNumeric,
+	)
+	tableWriter.Progress = dataTable.Progress
+
+	return tableWriter, nil
+}
+
+// Flushes a table's rows and commits them along with its manifest row.  The
+// caller rolls back if it fails.
+func finishCensusDataTable(dataTable *CensusTable, tableWriter *TableWriter) error {
+	if err := tableWriter.Close(); err != nil {
+		return err
+	}
+	checksums, err := dataTableChecksums(dataTable)
+	if err != nil {
+		return fmt.Errorf("%s: %s", dataTable.Name, err)
+	}
+	err = recordTableLoaded(tableWriter.Tx, dataTable.Name,
+		dataTable.RowCount, checksums,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %s", dataTable.Name, err)
+	}
+	if err := tableWriter.Tx.Commit(); err != nil {
+		return fmt.Errorf("error committing %s (%s)", dataTable.Name, err)
+	}
+	PROGRESS.Finish(dataTable.Progress)
+
+	return nil
+}
+
+// Reads an ACS table's estimate and margin of error files in step.
+func loadACSDataLocation(tableWriter *TableWriter, dataTable *CensusTable, dataLocation CensusDataLocation) (int, error) {
+	sources := make([]acs.Source, len(dataLocation.EstimateFiles))
+	for si := range sources {
+		estimates, err := dataLocation.EstimateFiles[si].Open()
+		if err != nil {
+			return 0, err
+		}
+		defer estimates.Close()
+		moes, err := dataLocation.MOEFiles[si].Open()
+		if err != nil {
+			return 0, err
+		}
+		defer moes.Close()
+
+		sources[si] = acs.Source{
+			Estimates: dataTable.Progress.Reader(estimates),
+			MOEs:      dataTable.Progress.Reader(moes),
+		}
+	}
+	tableReader, err := acs.NewTableReader(dataLocation.ACSTable, sources)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s (%s)", dataTable.Name, err)
+	}
+
+	rowCount := 0
+	for {
+		tableRow, err := tableReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s for %s (%s)",
+				dataTable.Name, sf1.States[dataLocation.State], err,
+			)
+		}
+		if err := tableWriter.WriteRow(tableRow.Values); err != nil {
+			return 0, err
+		}
+		rowCount++
+	}
+	log.Printf("%s: read %d rows for %s\n",
+		dataTable.Name, rowCount, sf1.States[dataLocation.State],
+	)
+
+	return rowCount, nil
+}
+
+func (vr *ValidationReport) addProblem(format string, args ...interface{}) {
+	vr.Problems = append(vr.Problems, fmt.Sprintf(format, args...))
+}
+
+// Checks that census data can be loaded without connecting to the database:
+// every file the packing lists name exists, table column counts match the
+// dictionary, and data and geographic lines are long enough.
+func validateCensusData() *ValidationReport {
+	report := new(ValidationReport)
+
+	if PRODUCT == "acs5" {
+		validateACSData(report)
+		return report
+	}
+	if PRODUCT == "pl2020" {
+		for _, state := range STATES {
+			validatePLData(state, report)
+		}
+		return report
+	}
+
+	api, err := GetAPIConcepts()
+	if err != nil {
+		report.addProblem("Error reading dictionary (%s)", err)
+	}
+
+	for _, state := range STATES {
+		validateStateCensusData(state, api, report)
+	}
+
+	return report
+}
+
+func validateStateCensusData(state string, api map[string]dictionary.Concept, report *ValidationReport) {
+	packingListFile, err := CENSUS_DATA.File(sf1.PackingListFileName(state))
+	if err != nil {
+		report.addProblem("Missing packing list (%s)", err)
+		return
+	}
+	packingListPath := packingListFile.Path
+	report.FileCount++
+
+	packingList, err := readPackingList(packingListFile)
+	if err != nil {
+		report.addProblem("Error reading packing list %s (%s)",
+			packingListPath, err,
+		)
+		return
+	}
+	fileNames := packingList.Files
+	if len(fileNames) == 0 {
+		report.addProblem("Packing list %s lists no data files",
+			packingListPath,
+		)
+	}
+
+	listedFiles := make(map[string]bool)
+	for _, fileName := range fileNames {
+		listedFiles[fileName] = true
+	}
+	if !listedFiles[sf1.GeoFileName(state)] {
+		report.addProblem("Packing list %s does not list geographic file %s",
+			packingListPath, sf1.GeoFileName(state),
+		)
+	}
+
+	for _, packingListTable := range packingList.Tables {
+		report.TableCount++
+		segmentFileName := sf1.DataFileName(state, packingListTable.FileNumber)
+		if !listedFiles[segmentFileName] {
+			report.addProblem(
+				"Table %s is in segment file %s, which the packing list "+
+					"does not list",
+				packingListTable.Name, segmentFileName,
+			)
+		}
+
+		if api == nil {
+			// The dictionary couldn't be read, which is already reported
+			continue
+		}
+		apiConcept, ok := api[packingListTable.Name]
+		if !ok {
+			report.addProblem("Dictionary lacks table %s",
+				packingListTable.Name,
+			)
+			continue
+		}
+		if len(apiConcept.Variables) != packingListTable.ColumnCount {
+			report.addProblem(
+				"Found column count mismatch in table %s (%d != %d)",
+				packingListTable.Name, len(apiConcept.Variables),
+				packingListTable.ColumnCount,
+			)
+		}
+	}
+
+	segmentFieldCounts := make(map[string]int)
+	for fileNumber, fieldCount := range packingList.SegmentFieldCounts() {
+		segmentFieldCounts[sf1.DataFileName(state, fileNumber)] = fieldCount
+	}
+	for _, fileName := range fileNames {
+		file, err := CENSUS_DATA.File(fileName)
+		if err != nil {
+			report.addProblem("Missing census data file (%s)", err)
+			continue
+		}
+		report.FileCount++
+
+		if fileName == sf1.GeoFileName(state) {
+			validateGeoFile(file, report)
+		} else if fieldCount, ok := segmentFieldCounts[fileName]; ok {
+			validateSegmentFile(file, fieldCount, report)
+		}
+	}
+}
+
+func validateACSData(report *ValidationReport) {
+	lookup, lookupPath, err := readACSLookup()
+	if err != nil {
+		report.addProblem("Error reading ACS lookup file %s (%s)",
+			lookupPath, err,
+		)
+		return
+	}
+	report.FileCount++
+	vintage, err := findACSVintage()
+	if err != nil {
+		report.addProblem("%s", err)
+		return
+	}
+	for _, table := range lookup.Tables {
+		if len(table.Lines) > 0 {
+			report.TableCount++
+		}
+	}
+
+	sequenceFieldCounts := lookup.SequenceFieldCounts()
+	for _, state := range STATES {
+		geoFile, err := CENSUS_DATA.File(acs.GeoFileName(vintage, state))
+		if err != nil {
+			report.addProblem("Missing geography file (%s)", err)
+		} else {
+			report.FileCount++
+			validateACSGeoFile(geoFile, report)
+		}
+
+		for _, sequence := range lookup.Sequences {
+			for _, fileName := range []string{
+				acs.EstimateFileName(vintage, state, sequence),
+				acs.MOEFileName(vintage, state, sequence),
+			} {
+				file, err := CENSUS_DATA.File(fileName)
+				if err != nil {
+					report.addProblem("Missing census data file (%s)", err)
+					continue
+				}
+				report.FileCount++
+				validateSegmentFile(
+					file, sequenceFieldCounts[sequence], report,
+				)
+			}
+		}
+	}
+}
+
+func validatePLData(state string, report *ValidationReport) {
+	report.TableCount += len(pl.Tables)
+
+	geoFile, err := CENSUS_DATA.File(pl.GeoFileName(state))
+	if err != nil {
+		report.addProblem("Missing geographic file (%s)", err)
+	} else {
+		report.FileCount++
+		validatePLGeoFile(geoFile, report)
+	}
+
+	for fileNumber, fieldCount := range pl.SegmentFieldCounts() {
+		file, err := CENSUS_DATA.File(pl.DataFileName(state, fileNumber))
+		if err != nil {
+			report.addProblem("Missing census data file (%s)", err)
+			continue
+		}
+		report.FileCount++
+		validateSegmentFile(file, fieldCount, report)
+	}
+}
+
+func validatePLGeoFile(censusFile *source.File, report *ValidationReport) {
+	file, err := censusFile.Open()
+	if err != nil {
+		report.addProblem("Error opening %s (%s)", censusFile.Path, err)
+		return
+	}
+	defer file.Close()
+
+	geoReader := pl.NewGeoReader(file, GEO_LAYOUT.Fields)
+	problem := fmt.Sprintf("do not have %d fields", len(GEO_LAYOUT.Fields))
+	validateFileLines(censusFile.Path, report, problem, func() error {
+		_, err := geoReader.Next()
+		return err
+	})
+}
+
+func validateACSGeoFile(censusFile *source.File, report *ValidationReport) {
+	file, err := censusFile.Open()
+	if err != nil {
+		report.addProblem("Error opening %s (%s)", censusFile.Path, err)
+		return
+	}
+	defer file.Close()
+
+	geoReader := acs.NewGeoReader(file)
+	problem := fmt.Sprintf("do not have %d fields", len(acs.GeoFields))
+	validateFileLines(censusFile.Path, report, problem, func() error {
+		_, err := geoReader.Next()
+		if lineErr, ok := err.(*acs.LineError); ok {
+			return &sf1.LineError{Line: lineErr.Line, Err: lineErr.Err}
+		}
+		return err
+	})
+}
+
+// Reads a census file to the end with next, which returns io.EOF at the end
+// and a *sf1.LineError for a bad line, reporting how many lines are bad and
+// the first one that is.
+func validateFileLines(filePath string, report *ValidationReport, problem string, next func() error) {
+	badLineCount := 0
+	firstBadLine := 0
+	for {
+		err := next()
+		if err == io.EOF {
+			break
+		}
+		if lineErr, ok := err.(*sf1.LineError); ok {
+			badLineCount++
+			if firstBadLine == 0 {
+				firstBadLine = lineErr.Line
+			}
+			continue
+		}
+		if err != nil {
+			report.addProblem("Error reading %s (%s)", filePath, err)
+			break
+		}
+	}
+	if badLineCount > 0 {
+		report.addProblem("%s: %d lines %s (first at line %d)",
+			filePath, badLineCount, problem, firstBadLine,
+		)
+	}
+}
+
+func validateSegmentFile(censusFile *source.File, fieldCount int, report *ValidationReport) {
+	file, err := censusFile.Open()
+	if err != nil {
+		report.addProblem("Error opening %s (%s)", censusFile.Path, err)
+		return
+	}
+	defer file.Close()
+
+	segmentReader := sf1.NewDelimitedSegmentReader(file, segmentDelimiter())
+	problem := fmt.Sprintf("have fewer than %d fields", fieldCount)
+	validateFileLines(censusFile.Path, report, problem, func() error {
+		row, err := segmentReader.Next()
+		if err != nil {
+			return err
+		}
+		if len(row.Fields) < fieldCount {
+			return &sf1.LineError{Line: row.Line, Err: errors.New(problem)}
+		}
+		return nil
+	})
+}
+
+func validateGeoFile(censusFile *source.File, report *ValidationReport) {
+	file, err := censusFile.Open()
+	if err != nil {
+		report.addProblem("Error opening %s (%s)", censusFile.Path, err)
+		return
+	}
+	defer file.Close()
+
+	geoReader := sf1.NewGeoReader(file, GEO_LAYOUT.Fields)
+	problem := fmt.Sprintf("are shorter than %d characters",
+		sf1.GeoRecordLength(GEO_LAYOUT.Fields),
+	)
+	validateFileLines(censusFile.Path, report, problem, func() error {
+		_, err := geoReader.Next()
+		return err
+	})
+}
+
+func printValidationReport(censusDataFolder string, report *ValidationReport) {
+	fmt.Printf("Validation report for %s\n", censusDataFolder)
+	fmt.Printf("  States: %s\n", strings.Join(STATES, ", "))
+	fmt.Printf("  Files checked: %d\n", report.FileCount)
+	fmt.Printf("  Tables checked: %d\n", report.TableCount)
+	fmt.Printf("  Problems: %d\n", len(report.Problems))
+	for _, problem := range report.Problems {
+		fmt.Printf("    - %s\n", problem)
+	}
+}
+
+func createIndex(tableName string, indexName string, method string, columns ...string) {
+	indexStartTime := time.Now()
+	dbExec(nil, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING %s (%s)",
+		quoteIdentifier(indexName), tableIdentifier(tableName), method,
+		quoteIdentifiers(columns),
+	))
+	log.Printf("Built index %s in %s\n",
+		indexName, time.Since(indexStartTime).Truncate(time.Millisecond),
+	)
+}
+
+// Returns the identifier of the table in the target schema or, failing that,
+// in the live one; or "" if neither has it.
+func findTable(tableName string) string {
+	if OUTPUT != nil {
+		// Without a database, only tables this run writes are known
+		if tableName == "tabblock" && len(TABBLOCK_FILES) > 0 {
+			return tableIdentifier(tableName)
+		}
+		return ""
+	}
+	for _, schema := range []string{SCHEMA, LIVE_SCHEMA} {
+		var tableExists bool
+
+		identifier := schemaTableIdentifier(schema, tableName)
+		err := DB.QueryRow(
+			"SELECT to_regclass($1) IS NOT NULL", identifier,
+		).Scan(&tableExists)
+		if err != nil {
+			log.Fatalf("Error looking up table %s (%s)\n", identifier, err)
+		}
+		if tableExists {
+			return identifier
+		}
+	}
+
+	return ""
+}
+
+func tableHasIndex(identifier string, columnName string, method string) bool {
+	var indexExists bool
+
+	err := DB.QueryRow(
+		"SELECT EXISTS ("+
+			"SELECT 1 FROM pg_index i "+
+			"JOIN pg_class c ON c.oid = i.indexrelid "+
+			"JOIN pg_am am ON am.oid = c.relam "+
+			"JOIN pg_attribute a ON a.attrelid = i.indrelid "+
+			"AND a.attnum = ANY(i.indkey) "+
+			"WHERE i.indrelid = to_regclass($1) "+
+			"AND a.attname = $2 AND am.amname = $3"+
+			")",
+		identifier, columnName, method,
+	).Scan(&indexExists)
+	if err != nil {
+		log.Fatalf("Error looking up indexes on %s (%s)\n", identifier, err)
+	}
+
+	return indexExists
+}
+
+// Builds the indexes the server's lookup query joins on, then refreshes the
+// planner statistics.  A staged load only indexes the tables it staged:
+// resuming after a swap leaves tables that are already live, with their
+// indexes, out of the staging schema.
+func buildIndexes() time.Duration {
+	log.Println("Building indexes")
+	indexStartTime := time.Now()
+
+	tableNames := append([]string{"geo_locations"}, SELECTED_TABLES...)
+	if STAGING {
+		tableNames = dataset.ManifestTables(tableNames, readManifest(SCHEMA))
+	}
+	for _, tableName := range tableNames {
+		if tableName == "geo_locations" {
+			createGeoLocationsIndexes()
+			continue
+		}
+		createIndex(tableName, fmt.Sprintf("idx_%s_logrecno", tableName),
+			"btree", "logrecno",
+		)
+	}
+
+	// A script's restore target can't be checked
+	if OUTPUT == nil {
+		if tabblock := findTable("tabblock"); len(tabblock) == 0 {
+			log.Println("Warning: table tabblock does not exist yet")
+		} else if !tableHasIndex(tabblock, "the_geom", "gist") {
+			log.Println(
+				"Warning: tabblock.the_geom has no GiST index, " +
+					"lookups will scan every block",
+			)
+		}
+	}
+
+	for _, tableName := range tableNames {
+		dbExec(nil, fmt.Sprintf("ANALYZE %s", tableIdentifier(tableName)))
+	}
+
+	indexElapsed := time.Since(indexStartTime)
+	log.Printf("Built indexes and statistics in %s\n",
+		indexElapsed.Truncate(time.Millisecond),
+	)
+
+	return indexElapsed
+}
+
+func createGeoLocationsIndexes() {
+	createIndex("geo_locations", "idx_geo_locations_logrecno", "btree",
+		"logrecno",
+	)
+	if PRODUCT == "acs5" {
+		createIndex("geo_locations", "idx_geo_locations_sumlevel", "btree",
+			"sumlevel",
+		)
+		createIndex("geo_locations", "idx_geo_locations_geoid", "btree",
+			"geoid",
+		)
+		return
+	}
+	createIndex("geo_locations", "idx_geo_locations_sumlev", "btree",
+		"sumlev",
+	)
+	createIndex("geo_locations", "idx_geo_locations_intpt", "btree",
+		"intptlat", "intptlon",
+	)
+	createIndex("geo_locations", "idx_geo_locations_geom", "gist", "geom")
+}
+
+// Joins block geometries to the block-level counts the server uses, keyed by
+// the 15-digit block GEOID (STATE+COUNTY+TRACT+BLOCK).
+func buildBlockDemographics() {
+	tables := BLOCK_DEMOGRAPHICS_TABLES
+	query := BLOCK_DEMOGRAPHICS_QUERY
+	if PRODUCT == "pl2020" {
+		tables = PL_BLOCK_DEMOGRAPHICS_TABLES
+		query = PL_BLOCK_DEMOGRAPHICS_QUERY
+	} else if PRODUCT != "sf1" {
+		return
+	}
+	for _, tableName := range tables {
+		if !TABLE_SELECTION.Selects(tableName) {
+			log.Printf("Skipping block_demographics, table %s not loaded\n",
+				tableName,
+			)
+			return
+		}
+	}
+	if len(findTable("tabblock")) == 0 {
+		log.Println("Skipping block_demographics, table tabblock not loaded")
+		return
+	}
+
+	log.Println("Building block_demographics")
+	buildStartTime := time.Now()
+
+	tx := dbBegin()
+	dbExec(tx, "DROP TABLE IF EXISTS "+tableIdentifier("block_demographics"))
+	if len(SCHEMA) > 0 {
+		// The table is created in the target schema, from tables there or,
+		// for a tabblock table that wasn't reloaded, in the live schema.
+		// PostGIS stays on the path.
+		searchPath := []string{quoteIdentifier(SCHEMA)}
+		if LIVE_SCHEMA != SCHEMA && LIVE_SCHEMA != "public" {
+			searchPath = append(searchPath, quoteIdentifier(LIVE_SCHEMA))
+		}
+		dbExec(tx, fmt.Sprintf("SET LOCAL search_path TO %s, public",
+			strings.Join(searchPath, ", "),
+		))
+	}
+	dbExec(tx, query)
+	dbExec(tx,
+		"ALTER TABLE "+tableIdentifier("block_demographics")+
+			" ADD PRIMARY KEY (geoid)",
+	)
+	dbExec(tx,
+		"CREATE INDEX idx_block_demographics_the_geom ON "+
+			tableIdentifier("block_demographics")+" USING gist (the_geom)",
+	)
+	dbCommit(tx)
+	dbExec(nil, "ANALYZE "+tableIdentifier("block_demographics"))
+
+	log.Printf("Built block_demographics in %s\n",
+		time.Since(buildStartTime).Truncate(time.Millisecond),
+	)
+}
+
+// Returns the tables in a schema, other than its load manifest.
+func schemaTables(schema string) []string {
+	rows, err := DB.Query(
+		"SELECT tablename FROM pg_tables "+
+			"WHERE schemaname = $1 AND tablename <> 'load_manifest' "+
+			"ORDER BY tablename",
+		schema,
+	)
+	if err != nil {
+		log.Fatalf("Error listing tables in schema %s (%s)\n", schema, err)
+	}
+	defer rows.Close()
+
+	tableNames := []string{}
+	for rows.Next() {
+		var tableName string
+
+		if err := rows.Scan(&tableName); err != nil {
+			log.Fatalf("Error listing tables in schema %s (%s)\n", schema, err)
+		}
+		tableNames = append(tableNames, tableName)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("Error listing tables in schema %s (%s)\n", schema, err)
+	}
+
+	return tableNames
+}
+
+// Checks that every table this run loaded is staged and holds the rows its
+// load recorded, so a load that went wrong never replaces live tables.
+// Returns the staged tables.
+func checkStagedTables() []string {
+	rowCounts := make(map[string]int64)
+
+	rows, err := DB.Query(
+		"SELECT table_name, row_count FROM " + tableIdentifier("load_manifest"),
+	)
+	if err != nil {
+		log.Fatalf("Error reading load manifest (%s)\n", err)
+	}
+	for rows.Next() {
+		var tableName string
+		var rowCount int64
+
+		if err := rows.Scan(&tableName, &rowCount); err != nil {
+			log.Fatalf("Error reading load manifest (%s)\n", err)
+		}
+		rowCounts[tableName] = rowCount
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("Error reading load manifest (%s)\n", err)
+	}
+	rows.Close()
+
+	loadedTables := append(
+		[]string{"geo_locations", "census_tables", "census_variables"},
+		SELECTED_TABLES...,
+	)
+	if len(TABBLOCK_FILES) > 0 {
+		loadedTables = append(loadedTables, "tabblock")
+	}
+	for _, tableName := range loadedTables {
+		if _, ok := rowCounts[tableName]; ok {
+			continue
+		}
+		// Resuming skips tables a previous run already swapped in
+		if _, ok := LIVE_MANIFEST[tableName]; ok && RESUME {
+			log.Printf("Table %s is already live, leaving it in place\n",
+				tableName,
+			)
+			continue
+		}
+		log.Fatalf("Table %s wasn't staged, leaving the live tables "+
+			"in place\n", tableName,
+		)
+	}
+	for tableName, rowCount := range rowCounts {
+		var stagedRowCount int64
+
+		err := DB.QueryRow(
+			"SELECT count(*) FROM " + tableIdentifier(tableName),
+		).Scan(&stagedRowCount)
+		if err != nil {
+			log.Fatalf("Error counting rows in staged table %s (%s)\n",
+				tableName, err,
+			)
+		}
+		if stagedRowCount != rowCount {
+			log.Fatalf("Staged table %s has %d rows, %d were loaded; "+
+				"leaving the live tables in place\n",
+				tableName, stagedRowCount, rowCount,
+			)
+		}
+	}
+
+	return schemaTables(SCHEMA)
+}
+
+func schemaHasTable(tx *sql.Tx, schema string, tableName string) bool {
+	var tableExists bool
+
+	identifier := schemaTableIdentifier(schema, tableName)
+	err := tx.QueryRow(
+		"SELECT to_regclass($1) IS NOT NULL", identifier,
+	).Scan(&tableExists)
+	if err != nil {
+		tx.Rollback()
+		log.Fatalf("Error looking up table %s (%s)\n", identifier, err)
+	}
+
+	return tableExists
+}
+
+// Moves tables, with their indexes, sequences and load manifest rows, from
+// one schema to another, replacing any already there.  Tables the first
+// schema doesn't have are left alone.
+func moveTables(tx *sql.Tx, from string, to string, tableNames []string) {
+	createLoadManifest(tx, from)
+	createLoadManifest(tx, to)
+	fromManifest := schemaTableIdentifier(from, "load_manifest")
+	toManifest := schemaTableIdentifier(to, "load_manifest")
+
+	for _, tableName := range tableNames {
+		if !schemaHasTable(tx, from, tableName) {
+			continue
+		}
+		dbExec(tx, "DROP TABLE IF EXISTS "+schemaTableIdentifier(to, tableName))
+		dbExec(tx, fmt.Sprintf("ALTER TABLE %s SET SCHEMA %s",
+			schemaTableIdentifier(from, tableName), quoteIdentifier(to),
 		))
+		dbExec(tx,
+			"DELETE FROM "+toManifest+" WHERE table_name = $1", tableName,
+		)
+		dbExec(tx,
+			"INSERT INTO "+toManifest+" "+
+				"(table_name, row_count, source_checksums, loaded_at) "+
+				"SELECT table_name, row_count, source_checksums, loaded_at "+
+				"FROM "+fromManifest+" WHERE table_name = $1",
+			tableName,
+		)
+		dbExec(tx,
+			"DELETE FROM "+fromManifest+" WHERE table_name = $1", tableName,
+		)
+	}
+}
+
+// Swaps the staged tables into the live schema in one transaction, so the
+// server sees either every old table or every new one.  The tables they
+// replace are kept in the previous schema until the next swap.
+func swapInStagedTables(tableNames []string) {
+	log.Printf("Swapping %d staged tables into schema '%s'\n",
+		len(tableNames), LIVE_SCHEMA,
+	)
+
+	tx := dbBegin()
+	dbExec(tx, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(LIVE_SCHEMA))
+	dbExec(tx, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(previousSchema()))
+	moveTables(tx, LIVE_SCHEMA, previousSchema(), tableNames)
+	moveTables(tx, SCHEMA, LIVE_SCHEMA, tableNames)
+	dbCommit(tx)
+
+	log.Printf("Swapped in staged tables, replaced tables kept in "+
+		"schema '%s'\n", previousSchema(),
+	)
+}
+
+// Swaps the previous generation of tables back into the live schema; the
+// tables they replace become the previous generation.
+func rollBackTables() {
+	tableNames := schemaTables(previousSchema())
+	if len(tableNames) == 0 {
+		log.Fatalf("No tables in schema %s to roll back to\n", previousSchema())
+	}
+	log.Printf("Rolling back %d tables in schema '%s'\n",
+		len(tableNames), LIVE_SCHEMA,
+	)
+
+	// The staging schema holds the live tables while they trade places
+	stagingSchema := LIVE_SCHEMA + config.StagingSuffix
+	tx := dbBegin()
+	dbExec(tx, "CREATE SCHEMA IF NOT EXISTS "+quoteIdentifier(stagingSchema))
+	moveTables(tx, LIVE_SCHEMA, stagingSchema, tableNames)
+	moveTables(tx, previousSchema(), LIVE_SCHEMA, tableNames)
+	moveTables(tx, stagingSchema, previousSchema(), tableNames)
+	dbCommit(tx)
+
+	log.Println("Rolled back")
+}
+
+func main() {
+	var censusDataFolder string
+
+	states := flag.String("state", DEFAULT_STATES,
+		"Comma-separated USPS codes of the states to load ('us' loads "+
+			"the national file)",
+	)
+	flag.StringVar(&PRODUCT, "product", PRODUCT,
+		"Census product in the data folder: 'sf1' (2010 Summary File 1), "+
+			"'acs5' (ACS 5-year summary file, with the geography file, "+
+			"the estimate and margin of error files and the sequence and "+
+			"table number lookup file) or 'pl2020' (2020 PL 94-171 "+
+			"redistricting data); detected from the folder's files if "+
+			"not given",
+	)
+	flag.StringVar(&DICTIONARY_FILE, "dictionary", DICTIONARY_FILE,
+		"Census API variables file (sf1.xml) to read table descriptions "+
+			"and variables from in place of the bundled dictionary",
+	)
+	tables := flag.String("tables", "",
+		"Comma-separated tables to load, by name or pattern "+
+			"(e.g. 'p11,p16,pct*'); defaults to all tables",
+	)
+	excludeTables := flag.String("exclude-tables", "",
+		"Comma-separated tables not to load, by name or pattern",
+	)
+	tabblockFiles := flag.String("tabblock", "",
+		"Comma-separated TIGER/Line tabblock shapefiles (.shp or .zip) "+
+			"to load into the tabblock table",
+	)
+	flag.BoolVar(&RESUME, "resume", RESUME,
+		"Skip tables a previous run already loaded from the same files",
+	)
+	flag.BoolVar(&VALIDATE, "validate", VALIDATE,
+		"Check the census data folder without connecting to the database",
+	)
+	flag.StringVar(&LOAD_METHOD, "load-method", LOAD_METHOD,
+		"How rows are written: 'copy' (batched COPY) or 'insert' "+
+			"(one INSERT per row, for databases that can't COPY)",
+	)
+	flag.IntVar(&COPY_BATCH_SIZE, "batch-size", COPY_BATCH_SIZE,
+		"Number of rows sent per COPY statement",
+	)
+	flag.IntVar(&WORKER_COUNT, "workers", WORKER_COUNT,
+		"Number of census data segments loaded at once; each takes a "+
+			"database connection per table from a pool bounded by "+
+			"max-open-conns",
+	)
+	inPlace := flag.Bool("in-place", false,
+		"Replace tables in place, for database users without CREATE on "+
+			"the database; by default tables are loaded into the "+
+			"schema's _staging schema and swapped in once they're "+
+			"checked, keeping the replaced tables in its _previous schema",
+	)
+	flag.BoolVar(&ROLLBACK, "rollback", ROLLBACK,
+		"Swap the schema's previous tables back in, then exit",
+	)
+	output := flag.String("output", "",
+		"Write the load to a psql script instead of the database: a .sql "+
+			"file, or with -output-format tsv a folder of TSV files and "+
+			"their load.sql",
+	)
+	outputFormat := flag.String("output-format", script.FormatSQL,
+		"Script format for -output: 'sql' (rows inline) or 'tsv' (rows "+
+			"in per-table TSV files)",
+	)
+	schema := flag.String("schema", "",
+		"PostgreSQL schema to load into, created if missing ('auto' "+
+			"names it after the product, vintage and states, e.g. "+
+			"sf1_2010_in); defaults to the connection's search path",
+	)
+	progressFormat := flag.String("progress", progress.FormatText,
+		"How load progress is reported: 'text' (log lines), 'json' (one "+
+			"event per line on stdout) or 'none'",
+	)
+	progressInterval := flag.Duration("progress-interval", 10*time.Second,
+		"How often load progress is reported",
+	)
+	CONFIG = config.RegisterFlags(flag.CommandLine, false)
+	flag.Usage = func() { printUsage("") }
+	flag.Parse()
+	if err := CONFIG.Load(); err != nil {
+		printUsage(err.Error())
+	}
+	PRINT_SQL_QUERIES = CONFIG.Debug()
+	STATES = parseStates(*states)
+	tableSelection, err := dataset.ParseTableSelection(*tables, *excludeTables)
+	if err != nil {
+		printUsage(err.Error())
+	}
+	TABLE_SELECTION = tableSelection
+	for _, tabblockFile := range strings.Split(*tabblockFiles, ",") {
+		if tabblockFile = strings.TrimSpace(tabblockFile); len(tabblockFile) > 0 {
+			TABBLOCK_FILES = append(TABBLOCK_FILES, tabblockFile)
+		}
+	}
+	knownProduct := len(PRODUCT) == 0
+	for _, product := range PRODUCTS {
+		knownProduct = knownProduct || product == PRODUCT
+	}
+	if !knownProduct {
+		printUsage(fmt.Sprintf("Unknown product %s", PRODUCT))
+	}
+	if LOAD_METHOD != "copy" && LOAD_METHOD != "insert" {
+		printUsage(fmt.Sprintf("Unknown load method %s", LOAD_METHOD))
+	}
+	if COPY_BATCH_SIZE < 1 {
+		printUsage("Batch size must be at least 1")
+	}
+	if WORKER_COUNT < 1 {
+		printUsage("Worker count must be at least 1")
+	}
+	knownOutputFormat := false
+	for _, format := range script.Formats {
+		knownOutputFormat = knownOutputFormat || format == *outputFormat
+	}
+	if !knownOutputFormat {
+		printUsage(fmt.Sprintf("Unknown output format %s", *outputFormat))
+	}
+	if len(*output) > 0 && (RESUME || ROLLBACK) {
+		printUsage("-resume and -rollback need a database, not -output")
+	}
+	if *inPlace {
+		STAGING = false
+	}
+	tracker, err := progress.NewTracker(*progressFormat, *progressInterval)
+	if err != nil {
+		printUsage(err.Error())
+	}
+	PROGRESS = tracker
+
+	if ROLLBACK {
+		if *schema == "auto" {
+			printUsage("Give -rollback the schema's name, not 'auto'")
+		}
+		SCHEMA = *schema
+		if len(SCHEMA) > 0 {
+			if err := config.CheckDataset(SCHEMA); err != nil {
+				printUsage(err.Error())
+			}
+		}
+		openDB()
+		LIVE_SCHEMA = SCHEMA
+		if len(LIVE_SCHEMA) == 0 {
+			LIVE_SCHEMA = currentSchema()
+		}
+		rollBackTables()
+		return
+	}
+
+	// Check for a specified census data folder
+	if flag.NArg() == 0 {
+		dataFolder, err := os.Getwd()
+		if err != nil {
+			log.Fatalf(
+				"Could not get current working folder: %s\n", err,
+			)
+		}
+		censusDataFolder = dataFolder
+	} else if flag.NArg() == 1 {
+		censusDataFolder = flag.Arg(0)
+	} else {
+		printUsage("")
 	}
-	if !fi.IsDir() {
+
+	// Find the census data files in the folder and its archives
+	censusData, err := source.Open(censusDataFolder)
+	if err != nil {
 		printUsage(fmt.Sprintf(
-			"Folder %s is not a folder", censusDataFolder,
+			"Could not read census data at %s (%s)", censusDataFolder, err,
 		))
 	}
+	CENSUS_DATA = censusData
+	defer CENSUS_DATA.Close()
 
-	openCensusDataFiles(censusDataFolder)
-	openDB()
+	if len(PRODUCT) == 0 {
+		PRODUCT = detectProduct()
+		log.Printf("Detected census product %s\n", PRODUCT)
+	}
+	if PRODUCT == "pl2020" {
+		VINTAGE = "2020"
+	}
+	loadGeoLayout()
 
-	// Spawn goroutines to load the data
-	go loadGeoLocationData(geoLocationDataLoaded)
-	go loadCensusData(censusDataLoaded)
+	if VALIDATE {
+		report := validateCensusData()
+		printValidationReport(censusDataFolder, report)
+		if len(report.Problems) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	openCensusDataFiles()
+	SCHEMA = resolveSchema(*schema)
+	if len(SCHEMA) > 0 {
+		if err := config.CheckDataset(SCHEMA); err != nil {
+			printUsage(err.Error())
+		}
+	}
+	if len(*output) > 0 {
+		openScriptOutput(*output, *outputFormat, censusDataFolder)
+	} else {
+		openDB()
+		setUpSchemas()
+		createLoadManifest(nil, SCHEMA)
+		if RESUME {
+			readLoadManifest()
+		}
+	}
+	selectedTables, dataTables := selectCensusTables()
+	startTime := time.Now()
+	PROGRESS.Run()
 
-	// Wait for the goroutines to finish
-	<-censusDataLoaded
+	// Load the data, stopping every worker if one fails or on an interrupt
+	interrupted, stopInterrupts := signal.NotifyContext(
+		context.Background(), os.Interrupt,
+	)
+	loads := NewLoadGroup(interrupted)
+	loads.Go(loadGeoLocationData)
+	loads.Go(func(ctx context.Context) error {
+		return loadCensusMetadata(ctx, selectedTables)
+	})
+	if len(TABBLOCK_FILES) > 0 {
+		loads.Go(loadTabblockData)
+	}
+	loadCensusData(loads, dataTables)
+	err = loads.Wait()
+	wasInterrupted := interrupted.Err() != nil
+	stopInterrupts()
+	PROGRESS.Stop()
+	if wasInterrupted {
+		log.Fatalf("Loading interrupted, tables not yet committed were " +
+			"rolled back\n",
+		)
+	}
+	if err != nil {
+		log.Fatalf("Loading failed, tables not yet committed were rolled "+
+			"back (%s)\n", err,
+		)
+	}
 	log.Println("Census data loaded")
-	<-geoLocationDataLoaded
-	log.Println("Geographic location data loaded")
+
+	loadElapsed := time.Since(startTime)
+	indexElapsed := buildIndexes()
+	buildBlockDemographics()
+	if STAGING {
+		swapInStagedTables(checkStagedTables())
+	}
+	if OUTPUT != nil {
+		if err := OUTPUT.Close(); err != nil {
+			log.Fatalf("Error writing script %s (%s)\n",
+				OUTPUT.ScriptPath, err,
+			)
+		}
+		log.Printf("Wrote script %s\n", OUTPUT.ScriptPath)
+	}
 
 	// Done!
-	log.Println("Loading complete")
+	log.Printf("Loading complete: wrote %d rows in %s (%.0f rows/sec, %s), "+
+		"built indexes in %s\n",
+		TOTAL_ROWS_WRITTEN, loadElapsed.Truncate(time.Second),
+		rowsPerSecond(int(TOTAL_ROWS_WRITTEN), loadElapsed), LOAD_METHOD,
+		indexElapsed.Truncate(time.Second),
+	)
+	if len(UNSELECTED_TABLES) > 0 {
+		log.Printf("Skipped %d tables not selected: %s\n",
+			len(UNSELECTED_TABLES), strings.Join(UNSELECTED_TABLES, ", "),
+		)
+	}
 }
@@ -0,0 +1,84 @@
+package pl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/camgunz/mapblue/backend/layout"
+	"github.com/camgunz/mapblue/backend/sf1"
+	"github.com/camgunz/mapblue/backend/source"
+)
+
+// A geographic header record: one value per field of the layout
+// (layout.Load("pl", 2020)), with surrounding spaces trimmed.
+type GeoRecord struct {
+	Line   int
+	Fields []layout.Field
+	Values []string
+}
+
+// Returns the value of the field with the given reference name, or "" if
+// the layout has no such field.
+func (record *GeoRecord) Value(referenceName string) string {
+	for fi, field := range record.Fields {
+		if field.ReferenceName == referenceName {
+			return record.Values[fi]
+		}
+	}
+
+	return ""
+}
+
+func (record *GeoRecord) LogRecNo() string {
+	return record.Value("LOGRECNO")
+}
+
+func (record *GeoRecord) StUSAB() string {
+	return record.Value("STUSAB")
+}
+
+// Reads the records of a geographic header file.
+type GeoReader struct {
+	Fields  []layout.Field
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewGeoReader(r io.Reader, fields []layout.Field) *GeoReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	return &GeoReader{Fields: fields, scanner: scanner}
+}
+
+// Returns the next record, or io.EOF after the last one.  A line with the
+// wrong number of fields returns a *sf1.LineError; reading can continue
+// past it.
+func (gr *GeoReader) Next() (*GeoRecord, error) {
+	for gr.scanner.Scan() {
+		gr.line++
+		line := gr.scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := strings.Split(line, Delimiter)
+		if len(fields) != len(gr.Fields) {
+			return nil, &sf1.LineError{Line: gr.line, Err: fmt.Errorf(
+				"%d fields, expected %d", len(fields), len(gr.Fields),
+			)}
+		}
+		for fi, field := range fields {
+			fields[fi] = strings.TrimSpace(source.DecodeLatin1(field))
+		}
+
+		return &GeoRecord{Line: gr.line, Fields: gr.Fields, Values: fields}, nil
+	}
+	if err := gr.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
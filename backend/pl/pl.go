@@ -0,0 +1,81 @@
+// Package pl reads the Census Bureau's 2020 PL 94-171 redistricting data
+// files: the pipe-delimited geographic header file and the three data
+// segment files.  The segments are laid out like SF1's, so rows are read
+// with package sf1's delimited readers, using the table layout in Tables.
+package pl
+
+import (
+	"fmt"
+
+	"github.com/camgunz/mapblue/backend/sf1"
+)
+
+const DataFileTemplate = "%s%05d2020.pl"
+const GeoFileTemplate = "%sgeo2020.pl"
+const Delimiter = "|"
+
+// The tables of the redistricting data, by segment.  There is no packing
+// list; the layout is fixed by the technical documentation.
+var Tables = []sf1.Table{
+	{Name: "p1", FileNumber: 1, ColumnOffset: 0, ColumnCount: 71},
+	{Name: "p2", FileNumber: 1, ColumnOffset: 71, ColumnCount: 73},
+	{Name: "p3", FileNumber: 2, ColumnOffset: 0, ColumnCount: 71},
+	{Name: "p4", FileNumber: 2, ColumnOffset: 71, ColumnCount: 73},
+	{Name: "h1", FileNumber: 2, ColumnOffset: 144, ColumnCount: 3},
+	{Name: "p5", FileNumber: 3, ColumnOffset: 0, ColumnCount: 10},
+}
+
+const SegmentCount = 3
+
+// A table's title and universe, as given in the technical documentation.
+type TableDescription struct {
+	Title    string
+	Universe string
+}
+
+var TableDescriptions = map[string]TableDescription{
+	"p1": {"RACE", "Total population"},
+	"p2": {
+		"HISPANIC OR LATINO, AND NOT HISPANIC OR LATINO BY RACE",
+		"Total population",
+	},
+	"p3": {
+		"RACE FOR THE POPULATION 18 YEARS AND OVER",
+		"Total population 18 years and over",
+	},
+	"p4": {
+		"HISPANIC OR LATINO, AND NOT HISPANIC OR LATINO BY RACE FOR THE " +
+			"POPULATION 18 YEARS AND OVER",
+		"Total population 18 years and over",
+	},
+	"h1": {"OCCUPANCY STATUS", "Housing units"},
+	"p5": {
+		"GROUP QUARTERS POPULATION BY MAJOR GROUP QUARTERS TYPE",
+		"Population in group quarters",
+	},
+}
+
+// Returns the name of a state's data segment file, e.g. "in000012020.pl".
+func DataFileName(state string, fileNumber int) string {
+	return fmt.Sprintf(DataFileTemplate, state, fileNumber)
+}
+
+// Returns the name of a state's geographic header file.
+func GeoFileName(state string) string {
+	return fmt.Sprintf(GeoFileTemplate, state)
+}
+
+// Returns the number of fields each segment file's lines hold.
+func SegmentFieldCounts() map[int]int {
+	fieldCounts := make(map[int]int)
+
+	for _, table := range Tables {
+		fieldCount := sf1.SegmentKeyColumns + table.ColumnOffset +
+			table.ColumnCount
+		if fieldCount > fieldCounts[table.FileNumber] {
+			fieldCounts[table.FileNumber] = fieldCount
+		}
+	}
+
+	return fieldCounts
+}
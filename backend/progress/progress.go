@@ -0,0 +1,314 @@
+// Package progress tracks how far along each table of a load is and
+// periodically reports bytes read, rows written, rows per second and an
+// estimated time remaining, per table and overall, as log lines or as one
+// JSON event per line.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const FormatText = "text"
+const FormatJSON = "json"
+const FormatNone = "none"
+
+var Formats = []string{FormatText, FormatJSON, FormatNone}
+
+// A table's progress.  Totals are known up front: TotalBytes from the sizes
+// of the files it reads, or TotalRows when the source counts its records.
+type Table struct {
+	Name       string
+	TotalBytes int64
+	TotalRows  int64
+
+	bytesRead   int64
+	rowsWritten int64
+	startTime   time.Time
+	endTime     time.Time
+	started     bool
+	done        bool
+}
+
+// An event as written in JSON mode.
+type Event struct {
+	Event         string  `json:"event"`
+	Time          string  `json:"time"`
+	Table         string  `json:"table,omitempty"`
+	BytesRead     int64   `json:"bytes_read"`
+	TotalBytes    int64   `json:"total_bytes"`
+	RowsWritten   int64   `json:"rows_written"`
+	TotalRows     int64   `json:"total_rows,omitempty"`
+	RowsPerSecond float64 `json:"rows_per_second"`
+	ETASeconds    float64 `json:"eta_seconds"`
+	Tables        int     `json:"tables,omitempty"`
+	TablesDone    int     `json:"tables_done,omitempty"`
+}
+
+type Tracker struct {
+	Format   string
+	Interval time.Duration
+
+	lock      sync.Mutex
+	tables    map[string]*Table
+	startTime time.Time
+	stop      chan bool
+	stopped   chan bool
+	output    io.Writer
+}
+
+func NewTracker(format string, interval time.Duration) (*Tracker, error) {
+	validFormat := false
+	for _, f := range Formats {
+		validFormat = validFormat || f == format
+	}
+	if !validFormat {
+		return nil, fmt.Errorf("unknown progress format %s", format)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("progress interval must be positive")
+	}
+
+	return &Tracker{
+		Format:    format,
+		Interval:  interval,
+		tables:    make(map[string]*Table),
+		startTime: time.Now(),
+		output:    os.Stdout,
+	}, nil
+}
+
+// Registers a table before it starts loading so the overall totals and ETA
+// include it.
+func (t *Tracker) Add(name string, totalBytes int64, totalRows int64) *Table {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	table := &Table{Name: name, TotalBytes: totalBytes, TotalRows: totalRows}
+	t.tables[name] = table
+
+	return table
+}
+
+func (t *Tracker) Start(table *Table) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	table.started = true
+	table.startTime = time.Now()
+}
+
+func (t *Tracker) Finish(table *Table) {
+	t.lock.Lock()
+	table.done = true
+	table.endTime = time.Now()
+	t.lock.Unlock()
+
+	t.emit(t.tableEvent("table_done", table, table.endTime))
+}
+
+func (table *Table) AddBytes(count int64) {
+	atomic.AddInt64(&table.bytesRead, count)
+}
+
+func (table *Table) AddRows(count int64) {
+	atomic.AddInt64(&table.rowsWritten, count)
+}
+
+// Wraps a reader so everything read through it counts toward the table.
+func (table *Table) Reader(r io.Reader) io.Reader {
+	return &countingReader{reader: r, table: table}
+}
+
+type countingReader struct {
+	reader io.Reader
+	table  *Table
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.table.AddBytes(int64(n))
+
+	return n, err
+}
+
+// Starts reporting every Interval until Stop is called.
+func (t *Tracker) Run() {
+	if t.Format == FormatNone {
+		return
+	}
+	t.lock.Lock()
+	t.startTime = time.Now()
+	t.lock.Unlock()
+	t.stop = make(chan bool)
+	t.stopped = make(chan bool)
+
+	go func() {
+		ticker := time.NewTicker(t.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.report()
+			case <-t.stop:
+				t.report()
+				close(t.stopped)
+				return
+			}
+		}
+	}()
+}
+
+func (t *Tracker) Stop() {
+	if t.stop == nil {
+		return
+	}
+	close(t.stop)
+	<-t.stopped
+	t.stop = nil
+}
+
+func (t *Tracker) report() {
+	now := time.Now()
+	events := []*Event{}
+
+	t.lock.Lock()
+	names := make([]string, 0, len(t.tables))
+	for name := range t.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	overall := &Event{Event: "overall", Tables: len(t.tables)}
+	for _, name := range names {
+		table := t.tables[name]
+		overall.BytesRead += atomic.LoadInt64(&table.bytesRead)
+		overall.TotalBytes += table.TotalBytes
+		overall.RowsWritten += atomic.LoadInt64(&table.rowsWritten)
+		if table.done {
+			overall.TablesDone++
+		}
+		if table.started && !table.done {
+			events = append(events, t.tableEvent("progress", table, now))
+		}
+	}
+	elapsed := now.Sub(t.startTime)
+	t.lock.Unlock()
+
+	overall.Time = now.Format(time.RFC3339)
+	overall.RowsPerSecond = perSecond(overall.RowsWritten, elapsed)
+	overall.ETASeconds = eta(overall.BytesRead, overall.TotalBytes, elapsed)
+
+	for _, event := range append(events, overall) {
+		t.emit(event)
+	}
+}
+
+func (t *Tracker) tableEvent(name string, table *Table, now time.Time) *Event {
+	elapsed := now.Sub(table.startTime)
+	event := &Event{
+		Event:       name,
+		Time:        now.Format(time.RFC3339),
+		Table:       table.Name,
+		BytesRead:   atomic.LoadInt64(&table.bytesRead),
+		TotalBytes:  table.TotalBytes,
+		RowsWritten: atomic.LoadInt64(&table.rowsWritten),
+		TotalRows:   table.TotalRows,
+	}
+	event.RowsPerSecond = perSecond(event.RowsWritten, elapsed)
+	if table.done {
+		event.ETASeconds = 0
+	} else if table.TotalRows > 0 {
+		event.ETASeconds = eta(event.RowsWritten, table.TotalRows, elapsed)
+	} else {
+		event.ETASeconds = eta(event.BytesRead, table.TotalBytes, elapsed)
+	}
+
+	return event
+}
+
+func (t *Tracker) emit(event *Event) {
+	switch t.Format {
+	case FormatJSON:
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Error encoding progress event (%s)\n", err)
+			return
+		}
+		t.lock.Lock()
+		fmt.Fprintln(t.output, string(data))
+		t.lock.Unlock()
+	case FormatText:
+		name := event.Table
+		if event.Event == "overall" {
+			name = fmt.Sprintf("overall (%d of %d tables done)",
+				event.TablesDone, event.Tables,
+			)
+		} else if event.Event == "table_done" {
+			name += " done"
+		}
+		read := fmt.Sprintf("%s of %s read, ",
+			formatBytes(event.BytesRead), formatBytes(event.TotalBytes),
+		)
+		written := fmt.Sprintf("%d rows written", event.RowsWritten)
+		if event.TotalRows > 0 {
+			read = ""
+			written = fmt.Sprintf("%d of %d rows written",
+				event.RowsWritten, event.TotalRows,
+			)
+		}
+		log.Printf("Progress %s: %s%s (%.0f rows/sec), ETA %s\n",
+			name, read, written, event.RowsPerSecond,
+			formatETA(event.ETASeconds),
+		)
+	}
+}
+
+func perSecond(count int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(count) / elapsed.Seconds()
+}
+
+// Estimates the seconds remaining from how long done took; -1 if unknown.
+func eta(done int64, total int64, elapsed time.Duration) float64 {
+	if done <= 0 || total <= 0 || elapsed <= 0 {
+		return -1
+	}
+	if done >= total {
+		return 0
+	}
+
+	return elapsed.Seconds() * float64(total-done) / float64(done)
+}
+
+func formatETA(seconds float64) string {
+	if seconds < 0 {
+		return "unknown"
+	}
+
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func formatBytes(count int64) string {
+	const unit = 1024
+	if count < unit {
+		return fmt.Sprintf("%d B", count)
+	}
+	div, exp := int64(unit), 0
+	for n := count / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(count)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,362 @@
+// Package script writes a load as a psql script instead of to a database, so
+// data can be prepared on a machine without PostgreSQL and restored on one
+// that can't see the census files.  The script runs in a single transaction:
+// restoring it either loads everything or, if it fails or was left
+// unfinished, nothing.
+//
+// In the sql format the script holds every table's rows inline, in COPY ...
+// FROM stdin blocks.  In the tsv format each table's rows go to a TSV file in
+// the output folder, next to a load.sql script that \copy's them in.
+package script
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+const FormatSQL = "sql"
+const FormatTSV = "tsv"
+
+var Formats = []string{FormatSQL, FormatTSV}
+
+// The name of the script in a tsv output folder.
+const TSVScriptName = "load.sql"
+
+var placeholderRegexp = regexp.MustCompile(`\$(\d+)`)
+
+// Escapes a value for COPY's text format.
+var copyEscaper = strings.NewReplacer(
+	`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`,
+)
+
+// A script being written.  Statements written with Exec go straight to the
+// script; a transaction's statements and rows are written when it commits,
+// so tables loaded at the same time don't interleave.
+type Output struct {
+	Path       string
+	Format     string
+	ScriptPath string
+
+	lock   sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// A load's transaction.  Rows are buffered in a TSV file until it commits.
+type Tx struct {
+	output *Output
+	ctx    context.Context
+	parts  []*part
+	done   bool
+}
+
+// A statement, or a table's rows.
+type part struct {
+	statement string
+
+	identifier string
+	columns    string
+	dataPath   string
+	dataFile   *os.File
+	dataWriter *bufio.Writer
+}
+
+// Opens a script: a .sql file in the sql format, or a folder (created if
+// missing) holding load.sql in the tsv format.  The header comment says what
+// the script loads.
+func Open(outputPath string, format string, header string) (*Output, error) {
+	output := &Output{Path: outputPath, Format: format}
+
+	switch format {
+	case FormatSQL:
+		output.ScriptPath = outputPath
+	case FormatTSV:
+		if err := os.MkdirAll(outputPath, 0755); err != nil {
+			return nil, err
+		}
+		output.ScriptPath = filepath.Join(outputPath, TSVScriptName)
+	default:
+		return nil, fmt.Errorf("unknown output format %s", format)
+	}
+
+	file, err := os.Create(output.ScriptPath)
+	if err != nil {
+		return nil, err
+	}
+	output.file = file
+	output.writer = bufio.NewWriter(file)
+
+	for _, line := range strings.Split(header, "\n") {
+		fmt.Fprintf(output.writer, "-- %s\n", line)
+	}
+	fmt.Fprint(output.writer, "\n\\set ON_ERROR_STOP on\n\nBEGIN;\n\n")
+
+	return output, nil
+}
+
+// Writes a statement, with its $1, $2, ... placeholders replaced by args.
+func (o *Output) Exec(query string, args ...interface{}) error {
+	statement, err := Interpolate(query, args)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.writeStatement(statement)
+}
+
+func (o *Output) writeStatement(statement string) error {
+	_, err := fmt.Fprintf(o.writer, "%s;\n", statement)
+
+	return err
+}
+
+// Starts a transaction.  Once ctx is cancelled its writes fail, as a
+// database transaction's would.
+func (o *Output) Begin(ctx context.Context) *Tx {
+	return &Tx{output: o, ctx: ctx}
+}
+
+// Finishes the script with COMMIT and closes it.
+func (o *Output) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, err := fmt.Fprint(o.writer, "\nCOMMIT;\n"); err != nil {
+		o.file.Close()
+		return err
+	}
+	if err := o.writer.Flush(); err != nil {
+		o.file.Close()
+		return err
+	}
+
+	return o.file.Close()
+}
+
+func (tx *Tx) check() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+
+	return tx.ctx.Err()
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	statement, err := Interpolate(query, args)
+	if err != nil {
+		return err
+	}
+	tx.parts = append(tx.parts, &part{statement: statement})
+
+	return nil
+}
+
+// Starts a table's rows.  identifier is the table's (quoted and qualified)
+// name and columns its quoted column list; name names its TSV file.
+func (tx *Tx) CopyIn(identifier string, columns string, name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	var dataFile *os.File
+	var err error
+	if tx.output.Format == FormatTSV {
+		dataFile, err = os.Create(filepath.Join(tx.output.Path, name+".tsv"))
+	} else {
+		dataFile, err = os.CreateTemp(
+			filepath.Dir(tx.output.Path), "."+name+"-*.tsv",
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx.parts = append(tx.parts, &part{
+		identifier: identifier,
+		columns:    columns,
+		dataPath:   dataFile.Name(),
+		dataFile:   dataFile,
+		dataWriter: bufio.NewWriter(dataFile),
+	})
+
+	return nil
+}
+
+// Writes a row of the table CopyIn last started.  nil values are NULL.
+func (tx *Tx) WriteRow(values []interface{}) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	if len(tx.parts) == 0 || tx.parts[len(tx.parts)-1].dataWriter == nil {
+		return fmt.Errorf("row written without CopyIn")
+	}
+	dataWriter := tx.parts[len(tx.parts)-1].dataWriter
+
+	for vi, value := range values {
+		if vi > 0 {
+			dataWriter.WriteByte('\t')
+		}
+		if value == nil {
+			dataWriter.WriteString(`\N`)
+			continue
+		}
+		dataWriter.WriteString(copyEscaper.Replace(fmt.Sprint(value)))
+	}
+	_, err := dataWriter.WriteString("\n")
+
+	return err
+}
+
+// Writes the transaction's statements and rows to the script.
+func (tx *Tx) Commit() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	tx.done = true
+	defer tx.removeTemporaryFiles()
+
+	for _, p := range tx.parts {
+		if p.dataFile == nil {
+			continue
+		}
+		if err := p.dataWriter.Flush(); err != nil {
+			return err
+		}
+		if err := p.dataFile.Close(); err != nil {
+			return err
+		}
+		p.dataFile = nil
+	}
+
+	tx.output.lock.Lock()
+	defer tx.output.lock.Unlock()
+
+	for _, p := range tx.parts {
+		if p.dataWriter == nil {
+			if err := tx.output.writeStatement(p.statement); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.output.writeRows(p); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(tx.output.writer)
+
+	return err
+}
+
+func (o *Output) writeRows(p *part) error {
+	if o.Format == FormatTSV {
+		_, err := fmt.Fprintf(o.writer, "\\copy %s (%s) FROM %s\n",
+			p.identifier, p.columns,
+			pq.QuoteLiteral(filepath.Base(p.dataPath)),
+		)
+		return err
+	}
+
+	dataFile, err := os.Open(p.dataPath)
+	if err != nil {
+		return err
+	}
+	defer dataFile.Close()
+
+	fmt.Fprintf(o.writer, "COPY %s (%s) FROM stdin;\n", p.identifier, p.columns)
+	if _, err := io.Copy(o.writer, dataFile); err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(o.writer, "\\.\n")
+
+	return err
+}
+
+// Drops the transaction's statements and rows.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+
+	for _, p := range tx.parts {
+		if p.dataFile != nil {
+			p.dataFile.Close()
+			p.dataFile = nil
+		}
+		if tx.output.Format == FormatTSV && len(p.dataPath) > 0 {
+			os.Remove(p.dataPath)
+		}
+	}
+	tx.removeTemporaryFiles()
+
+	return nil
+}
+
+// Removes the files rows were buffered in for the sql format, whose rows end
+// up in the script.
+func (tx *Tx) removeTemporaryFiles() {
+	if tx.output.Format != FormatSQL {
+		return
+	}
+	for _, p := range tx.parts {
+		if p.dataFile != nil {
+			p.dataFile.Close()
+			p.dataFile = nil
+		}
+		if len(p.dataPath) > 0 {
+			os.Remove(p.dataPath)
+		}
+	}
+}
+
+// Replaces a query's $1, $2, ... placeholders with args as SQL literals.
+func Interpolate(query string, args []interface{}) (string, error) {
+	var err error
+
+	statement := placeholderRegexp.ReplaceAllStringFunc(query,
+		func(placeholder string) string {
+			index, _ := strconv.Atoi(placeholder[1:])
+			if index < 1 || index > len(args) {
+				err = fmt.Errorf("no value for %s in query %s",
+					placeholder, query,
+				)
+				return placeholder
+			}
+			return literal(args[index-1])
+		},
+	)
+
+	return statement, err
+}
+
+func literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return pq.QuoteLiteral(v)
+	}
+
+	return pq.QuoteLiteral(fmt.Sprint(value))
+}
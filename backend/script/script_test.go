@@ -0,0 +1,196 @@
+package script
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		want  string
+		err   bool
+	}{
+		{
+			name:  "numbers and strings",
+			query: "INSERT INTO t VALUES ($1, $2, $3)",
+			args:  []interface{}{"p11", 42, int64(7)},
+			want:  "INSERT INTO t VALUES ('p11', 42, 7)",
+		},
+		{
+			name:  "quotes",
+			query: "SELECT $1",
+			args:  []interface{}{"O'Brien"},
+			want:  "SELECT 'O''Brien'",
+		},
+		{
+			name:  "backslashes",
+			query: "SELECT $1",
+			args:  []interface{}{`C:\census`},
+			want:  `SELECT  E'C:\\census'`,
+		},
+		{
+			name:  "NULL",
+			query: "SELECT $1",
+			args:  []interface{}{nil},
+			want:  "SELECT NULL",
+		},
+		{
+			name:  "other types are quoted",
+			query: "SELECT $1",
+			args:  []interface{}{1.5},
+			want:  "SELECT '1.5'",
+		},
+		{
+			name:  "placeholders past nine",
+			query: "SELECT $10, $1",
+			args: []interface{}{
+				1, 2, 3, 4, 5, 6, 7, 8, 9, "tenth",
+			},
+			want: "SELECT 'tenth', 1",
+		},
+		{
+			name:  "repeated placeholders",
+			query: "SELECT $1 WHERE $1 IS NOT NULL",
+			args:  []interface{}{"x"},
+			want:  "SELECT 'x' WHERE 'x' IS NOT NULL",
+		},
+		{
+			name:  "missing argument",
+			query: "SELECT $1, $2",
+			args:  []interface{}{"x"},
+			err:   true,
+		},
+		{
+			name:  "no arguments",
+			query: "ANALYZE",
+			want:  "ANALYZE",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Interpolate(test.query, test.args)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteRow(t *testing.T) {
+	rows := [][]interface{}{
+		{"tab\there", `back\slash`, 42},
+		{"new\nline", "carriage\rreturn", nil},
+	}
+	wantRows := "tab\\there\tback\\\\slash\t42\n" +
+		"new\\nline\tcarriage\\rreturn\t\\N\n"
+
+	tests := []struct {
+		format     string
+		outputName string
+		want       string
+	}{
+		{
+			format:     FormatSQL,
+			outputName: "in.sql",
+			want: `COPY "public"."p11" ("a", "b", "c") FROM stdin;` + "\n" +
+				wantRows + "\\.\n",
+		},
+		{
+			format:     FormatTSV,
+			outputName: "in",
+			want: `\copy "public"."p11" ("a", "b", "c") FROM 'p11.tsv'` +
+				"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			tempDir := t.TempDir()
+			output, err := Open(
+				filepath.Join(tempDir, test.outputName), test.format, "test",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx := output.Begin(context.Background())
+			err = tx.CopyIn(`"public"."p11"`, `"a", "b", "c"`, "p11")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				if err := tx.WriteRow(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if err := output.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			script, err := os.ReadFile(output.ScriptPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(script), test.want) {
+				t.Errorf("script %s lacks %q", script, test.want)
+			}
+			if !strings.HasSuffix(string(script), "\nCOMMIT;\n") {
+				t.Errorf("script %s doesn't end with COMMIT", script)
+			}
+
+			if test.format == FormatTSV {
+				data, err := os.ReadFile(filepath.Join(output.Path, "p11.tsv"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != wantRows {
+					t.Errorf("p11.tsv = %q, want %q", data, wantRows)
+				}
+			}
+			// The sql format's temporary row files are gone once committed
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("output folder holds %d files, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestCancelledTx(t *testing.T) {
+	output, err := Open(filepath.Join(t.TempDir(), "in.sql"), FormatSQL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := output.Begin(ctx)
+	if err := tx.Exec("SELECT $1", 1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := tx.Commit(); err != context.Canceled {
+		t.Errorf("got %v committing, want context.Canceled", err)
+	}
+}
@@ -5,22 +5,28 @@ import (
 	"compress/zlib"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/camgunz/mapblue/backend/config"
+	"github.com/lib/pq"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// Unmarried and Childless are null for blocks loaded from the 2020
+// redistricting data, which has no household tables.
 type CensusBlockProperties struct {
 	Name      string `json:"name"`
 	Over18    int    `json:"over18"`
 	Black     int    `json:"black"`
 	Hispanic  int    `json:"hispanic"`
 	OtherRace int    `json:"otherRace"`
-	Unmarried int    `json:"unmarried"`
-	Childless int    `json:"childless"`
+	Unmarried *int   `json:"unmarried"`
+	Childless *int   `json:"childless"`
 }
 
 type CensusBlock struct {
@@ -35,29 +41,38 @@ type CensusBlocks struct {
 	Features []CensusBlock `json:"features"`
 }
 
-const postgresAddress = "/var/run/postgresql"
-const postgresUser = "census"
-const postgresDatabase = "census"
-const postgresSSLMode = "disable"
+// A census table and, when one table is asked for, its variables.
+type CensusTable struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Universe    string           `json:"universe"`
+	Segments    []int64          `json:"segments"`
+	Variables   []CensusVariable `json:"variables,omitempty"`
+}
+
+type CensusVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
 
-// const hostAddressAndPort = "127.0.0.1:8080"
-const hostAddressAndPort = "0.0.0.0:8080"
 const blockChunkSize = 3000
-const blockQueryTemplate = "SELECT tb.tabblock_id, tb.name, " +
-	"ST_AsGeoJSON(tb.the_geom), " +
-	"p11.p0110006, p11.p0110007, p11.p0110008, p11.p0110009, p11.p0110010, " +
-	"p11.p0110011, p11.p0110002, p16.p0160003, p19.p0190009, p19.p0190013, " +
-	"p19.p0190016, p29.p0290007, p29.p0290015, p29.p0290018 " +
-	"FROM tabblock AS tb, geo_locations as gl, p11, p16, p19, p29 " +
+const blockQueryTemplate = "SELECT geoid, name, ST_AsGeoJSON(the_geom), " +
+	"over18, black, hispanic, other_race, unmarried, childless " +
+	"FROM %s " +
 	"WHERE ST_Intersects(the_geom, ST_GeomFromEWKT(" +
 	"'SRID=4269;MULTIPOLYGON(((%s %s, %s %s, %s %s, %s %s, %s %s)))'" +
-	")) " +
-	"AND gl.sumlev IN ('101', '750', '755') " +
-	"AND gl.intptlon = tb.intptlon AND gl.intptlat = tb.intptlat " +
-	"AND p11.logrecno = gl.logrecno AND p16.logrecno = gl.logrecno " +
-	"AND p19.logrecno = gl.logrecno AND p29.logrecno = gl.logrecno;"
+	"));"
 
 var db *sql.DB
+var cfg *config.Config
+
+// Schemas holding a block_demographics table, refreshed when a lookup asks
+// for one that isn't known yet.
+var datasets []string
+var datasetsLock sync.Mutex
+
+// Loader schema names: product, vintage, then states, e.g. sf1_2010_in.
+var datasetNameRegexp = regexp.MustCompile(`^([a-z0-9]+)_(\d{4})_(.+)$`)
 
 func send400(w http.ResponseWriter, msg string) {
 	w.WriteHeader(http.StatusBadRequest)
@@ -84,6 +99,268 @@ func checkParam(w http.ResponseWriter, r *http.Request, paramName string) bool {
 	return true
 }
 
+func findDatasets() ([]string, error) {
+	rows, err := db.Query(
+		"SELECT table_schema FROM information_schema.tables " +
+			"WHERE table_name = 'block_demographics' ORDER BY table_schema",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []string{}
+	for rows.Next() {
+		var schema string
+
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		found = append(found, schema)
+	}
+
+	return found, rows.Err()
+}
+
+// Returns the known datasets, reading them from the database if there are
+// none yet or refresh is set.
+func getDatasets(refresh bool) ([]string, error) {
+	datasetsLock.Lock()
+	defer datasetsLock.Unlock()
+
+	if datasets == nil || refresh {
+		found, err := findDatasets()
+		if err != nil {
+			return nil, err
+		}
+		datasets = found
+	}
+
+	return datasets, nil
+}
+
+func hasDataset(name string) (bool, error) {
+	for _, refresh := range []bool{false, true} {
+		known, err := getDatasets(refresh)
+		if err != nil {
+			return false, err
+		}
+		for _, dataset := range known {
+			if dataset == name {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the datasets with the given vintage, keeping only those covering
+// the same states as the default dataset if it has any.
+func datasetsForVintage(vintage string) ([]string, error) {
+	states := ""
+	if match := datasetNameRegexp.FindStringSubmatch(cfg.Dataset); match != nil {
+		states = match[3]
+	}
+
+	candidates := []string{}
+	for _, refresh := range []bool{false, true} {
+		known, err := getDatasets(refresh)
+		if err != nil {
+			return nil, err
+		}
+		for _, dataset := range known {
+			if strings.HasSuffix(dataset, config.StagingSuffix) ||
+				strings.HasSuffix(dataset, config.PreviousSuffix) {
+				continue
+			}
+			match := datasetNameRegexp.FindStringSubmatch(dataset)
+			if match == nil || match[2] != vintage {
+				continue
+			}
+			if len(states) > 0 && match[3] != states {
+				continue
+			}
+			candidates = append(candidates, dataset)
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+
+	return candidates, nil
+}
+
+// Returns the dataset the request asks for with its dataset or vintage
+// parameter, or the default dataset.
+func requestDataset(w http.ResponseWriter, r *http.Request) (string, bool) {
+	form := r.URL.Query()
+	dataset := cfg.Dataset
+
+	if names, ok := form["dataset"]; ok {
+		dataset = names[0]
+		if err := config.CheckDataset(dataset); err != nil {
+			send400(w, "Invalid value for dataset")
+			return "", false
+		}
+		found, err := hasDataset(dataset)
+		if err != nil {
+			send500(w, err)
+			return "", false
+		}
+		if !found {
+			send400(w, fmt.Sprintf("Unknown dataset %s", dataset))
+			return "", false
+		}
+	} else if vintages, ok := form["vintage"]; ok {
+		vintage := vintages[0]
+		if _, err := strconv.Atoi(vintage); err != nil || len(vintage) != 4 {
+			send400(w, "Invalid value for vintage")
+			return "", false
+		}
+		candidates, err := datasetsForVintage(vintage)
+		if err != nil {
+			send500(w, err)
+			return "", false
+		}
+		if len(candidates) == 0 {
+			send400(w, fmt.Sprintf("No dataset has vintage %s", vintage))
+			return "", false
+		}
+		if len(candidates) > 1 {
+			send400(w, fmt.Sprintf(
+				"Several datasets have vintage %s (%s), give a dataset",
+				vintage, strings.Join(candidates, ", "),
+			))
+			return "", false
+		}
+		dataset = candidates[0]
+	}
+
+	return dataset, true
+}
+
+// Qualifies a table's name with its dataset's schema, if one is set.
+func datasetTable(dataset string, tableName string) string {
+	if len(dataset) == 0 {
+		return tableName
+	}
+
+	return pq.QuoteIdentifier(dataset) + "." + tableName
+}
+
+// Returns true if err is PostgreSQL's error for a missing table.
+func isUndefinedTable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+
+	return ok && pqErr.Code == "42P01"
+}
+
+// Describes the census tables a dataset holds, or with a table parameter one
+// table and its variables, from the metadata the loader writes.
+func describeTables(w http.ResponseWriter, r *http.Request) {
+	form := r.URL.Query()
+
+	cfg.Debugf("%s %s", r.Method, r.URL)
+	dataset, ok := requestDataset(w, r)
+	if !ok {
+		return
+	}
+	tableQuery := "SELECT table_name, description, universe, segments " +
+		"FROM " + datasetTable(dataset, "census_tables")
+	args := []interface{}{}
+	tableName := ""
+	if names, ok := form["table"]; ok {
+		tableName = strings.ToLower(names[0])
+		tableQuery += " WHERE table_name = $1"
+		args = append(args, tableName)
+	}
+
+	tableRows, err := db.Query(tableQuery+" ORDER BY table_name", args...)
+	if isUndefinedTable(err) {
+		send400(w, "Dataset has no census metadata, reload it")
+		return
+	}
+	if err != nil {
+		send500(w, err)
+		return
+	}
+	defer tableRows.Close()
+
+	censusTables := []CensusTable{}
+	for tableRows.Next() {
+		var censusTable CensusTable
+		var description, universe sql.NullString
+
+		err = tableRows.Scan(&censusTable.Name, &description, &universe,
+			pq.Array(&censusTable.Segments),
+		)
+		if err != nil {
+			send500(w, err)
+			return
+		}
+		censusTable.Description = description.String
+		censusTable.Universe = universe.String
+		censusTables = append(censusTables, censusTable)
+	}
+	if err = tableRows.Err(); err != nil {
+		send500(w, err)
+		return
+	}
+
+	var response interface{} = censusTables
+	if len(tableName) > 0 {
+		if len(censusTables) == 0 {
+			send400(w, fmt.Sprintf("Unknown table %s", tableName))
+			return
+		}
+		censusTable := censusTables[0]
+		censusTable.Variables, err = tableVariables(
+			datasetTable(dataset, "census_variables"), tableName,
+		)
+		if err != nil {
+			send500(w, err)
+			return
+		}
+		response = censusTable
+	}
+
+	jsonData, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		send500(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	fmt.Fprint(w, string(jsonData))
+}
+
+func tableVariables(variablesTable string, tableName string) ([]CensusVariable, error) {
+	rows, err := db.Query(
+		"SELECT variable_name, label FROM "+variablesTable+
+			" WHERE table_name = $1 ORDER BY position",
+		tableName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variables := []CensusVariable{}
+	for rows.Next() {
+		var variable CensusVariable
+		var label sql.NullString
+
+		if err := rows.Scan(&variable.Name, &label); err != nil {
+			return nil, err
+		}
+		variable.Label = label.String
+		variables = append(variables, variable)
+	}
+
+	return variables, rows.Err()
+}
+
 func lookup(w http.ResponseWriter, r *http.Request) {
 	var supportedEncodings = r.Header.Get("Accept-Encoding")
 	var supportsGZIP = strings.Contains(supportedEncodings, "gzip")
@@ -91,6 +368,7 @@ func lookup(w http.ResponseWriter, r *http.Request) {
 	var lat1, lon1, lat2, lon2 string
 	form := r.URL.Query()
 
+	cfg.Debugf("%s %s", r.Method, r.URL)
 	if checkParam(w, r, "lat1") && checkParam(w, r, "lon1") &&
 		checkParam(w, r, "lat2") && checkParam(w, r, "lon2") {
 		lat1 = form["lat1"][0]
@@ -100,28 +378,30 @@ func lookup(w http.ResponseWriter, r *http.Request) {
 	} else {
 		return
 	}
+	dataset, ok := requestDataset(w, r)
+	if !ok {
+		return
+	}
+	table := datasetTable(dataset, "block_demographics")
 
 	censusBlocks := CensusBlocks{}
 	censusBlocks.Type = "FeatureCollection"
 	censusBlocks.Features = make([]CensusBlock, blockChunkSize)
 	blockCount := 0
 
-	blockRows, err := db.Query(fmt.Sprintf(blockQueryTemplate,
+	blockRows, err := db.Query(fmt.Sprintf(blockQueryTemplate, table,
 		lon1, lat1, lon2, lat1, lon2, lat2, lon1, lat2, lon1, lat1,
 	))
 	if err != nil {
 		send500(w, err)
 		return
 	}
+	defer blockRows.Close()
 
 	for blockRows.Next() {
 		var (
 			blockID, name, geoJSONData string
-			blacks, aians, asians, nhopis, others, multis, hispanics, over18,
-			childlessHusbandAndWifeFamilies, childlessMaleFamilies,
-			childlessFemaleFamilies, spouses, sonsOrDaughtersInLaw,
-			unrelatedRoommates int
-			geoJSON interface{}
+			geoJSON                    interface{}
 		)
 
 		if blockCount >= len(censusBlocks.Features) {
@@ -131,12 +411,12 @@ func lookup(w http.ResponseWriter, r *http.Request) {
 			copy(newBlocks, censusBlocks.Features)
 			censusBlocks.Features = newBlocks
 		}
+		block := &censusBlocks.Features[blockCount]
 		err = blockRows.Scan(
-			&blockID, &name, &geoJSONData, &blacks, &aians, &asians, &nhopis,
-			&others, &multis, &hispanics, &over18,
-			&childlessHusbandAndWifeFamilies, &childlessMaleFamilies,
-			&childlessFemaleFamilies, &spouses, &sonsOrDaughtersInLaw,
-			&unrelatedRoommates,
+			&blockID, &name, &geoJSONData, &block.Properties.Over18,
+			&block.Properties.Black, &block.Properties.Hispanic,
+			&block.Properties.OtherRace, &block.Properties.Unmarried,
+			&block.Properties.Childless,
 		)
 		if err != nil {
 			send500(w, err)
@@ -149,22 +429,10 @@ func lookup(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		block := &censusBlocks.Features[blockCount]
 		block.ID = blockID
 		block.Type = "Feature"
 		block.Geometry = geoJSON.(map[string]interface{})
 		block.Properties.Name = name
-		block.Properties.Over18 = over18
-		block.Properties.Black = blacks
-		block.Properties.Hispanic = hispanics
-		block.Properties.OtherRace =
-			aians + asians + nhopis + others + multis
-		block.Properties.Unmarried =
-			over18 - ((spouses * 2) + (sonsOrDaughtersInLaw * 2))
-		block.Properties.Childless = unrelatedRoommates +
-			(childlessHusbandAndWifeFamilies * 2) +
-			childlessMaleFamilies +
-			childlessFemaleFamilies
 
 		blockCount++
 	}
@@ -207,19 +475,26 @@ func lookup(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	pg, err := sql.Open(
-		"postgres", fmt.Sprintf("host=%s dbname=%s user=%s sslmode=%s",
-			postgresAddress, postgresDatabase, postgresUser, postgresSSLMode,
-		),
-	)
+	cfg = config.RegisterFlags(flag.CommandLine, true)
+	flag.Parse()
+	if err := cfg.Load(); err != nil {
+		log.Fatal(err)
+	}
+
+	pg, err := sql.Open("postgres", cfg.DSN)
 	if err != nil {
 		log.Fatal(err)
 	}
 	db = pg
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
 
 	http.HandleFunc("/", lookup)
-	fmt.Printf("Listening on %s\n", hostAddressAndPort)
-	http.ListenAndServe(hostAddressAndPort, nil)
+	http.HandleFunc("/tables", describeTables)
+	fmt.Printf("Listening on %s\n", cfg.ListenAddress)
+	if err = http.ListenAndServe(cfg.ListenAddress, nil); err != nil {
+		log.Print(err)
+	}
 	if err = db.Close(); err != nil {
 		log.Fatal(err)
 	}
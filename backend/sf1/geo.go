@@ -0,0 +1,111 @@
+package sf1
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/camgunz/mapblue/backend/layout"
+	"github.com/camgunz/mapblue/backend/source"
+)
+
+// A field in the fixed-width geographic header record.  Layouts are defined
+// per vintage by package layout; the 2010 one is layout.Load("sf1", 2010).
+type GeoField = layout.Field
+
+// A geographic header record: one value per field, decoded from Latin-1 and
+// with surrounding spaces trimmed.
+type GeoRecord struct {
+	Line   int
+	Fields []GeoField
+	Values []string
+}
+
+// Returns the value of the field with the given reference name, e.g.
+// "SUMLEV", or "" if the layout has no such field.
+func (record *GeoRecord) Value(referenceName string) string {
+	for fi, field := range record.Fields {
+		if field.ReferenceName == referenceName {
+			return record.Values[fi]
+		}
+	}
+
+	return ""
+}
+
+func (record *GeoRecord) LogRecNo() string {
+	return record.Value("LOGRECNO")
+}
+
+func (record *GeoRecord) StUSAB() string {
+	return record.Value("STUSAB")
+}
+
+// Reads geographic header records from a geo file.
+type GeoReader struct {
+	Fields []GeoField
+	lines  *lineReader
+}
+
+func NewGeoReader(r io.Reader, fields []GeoField) *GeoReader {
+	return &GeoReader{Fields: fields, lines: newLineReader(r)}
+}
+
+// Returns the next record, or io.EOF after the last one.  A line too short
+// for the layout returns a *LineError; reading can continue past it.
+func (gr *GeoReader) Next() (*GeoRecord, error) {
+	line, lineNumber, err := gr.lines.next()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &GeoRecord{
+		Line:   lineNumber,
+		Fields: gr.Fields,
+		Values: make([]string, len(gr.Fields)),
+	}
+	for fi, field := range gr.Fields {
+		startIndex := field.Position - 1
+		endIndex := startIndex + field.Size
+		if startIndex < 0 || startIndex == endIndex || endIndex > len(line) {
+			return nil, &LineError{lineNumber, fmt.Errorf(
+				"value indices for %s (len: %d, %d:%d) out of range",
+				field.ReferenceName, len(line), startIndex, endIndex,
+			)}
+		}
+		record.Values[fi] = strings.TrimSpace(
+			source.DecodeLatin1(line[startIndex:endIndex]),
+		)
+	}
+
+	return record, nil
+}
+
+// Returns the record length a geographic header layout needs.
+func GeoRecordLength(fields []GeoField) int {
+	length := 0
+	for _, field := range fields {
+		if end := field.Position - 1 + field.Size; end > length {
+			length = end
+		}
+	}
+
+	return length
+}
+
+// Parses a signed internal point coordinate.  2010 and 2020 files write them
+// with a decimal point ("+39.7684030", "-086.1581370"); 2000 files imply six
+// decimal places ("+39768403").
+func ParseCoordinate(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	coordinate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad coordinate %q", value)
+	}
+	if !strings.Contains(value, ".") {
+		coordinate /= 1e6
+	}
+
+	return coordinate, nil
+}
@@ -0,0 +1,89 @@
+package sf1
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseCoordinate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		err   bool
+	}{
+		{"+39.7684030", 39.768403, false},
+		{"-086.1581370", -86.158137, false},
+		{" +39.7684030 ", 39.768403, false},
+		{"+39768403", 39.768403, false},
+		{"-086158137", -86.158137, false},
+		{"+0", 0, false},
+		{"", 0, true},
+		{"N39.7684030", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseCoordinate(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: got %v, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.value, err)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%q: got %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestGeoReader(t *testing.T) {
+	fields := []GeoField{
+		{ReferenceName: "STUSAB", Size: 2, Position: 1},
+		{ReferenceName: "LOGRECNO", Size: 7, Position: 3},
+		{ReferenceName: "INTPTLAT", Size: 11, Position: 10},
+		{ReferenceName: "NAME", Size: 8, Position: 21},
+	}
+	// Names are Latin-1, as in Doña Ana County, NM
+	data := "IN0000001+39.7684030Do\xf1a Ana\n" +
+		"IN0000002\n" +
+		"IN0000003  +39768403        \n"
+	reader := NewGeoReader(strings.NewReader(data), fields)
+
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.StUSAB() != "IN" || record.LogRecNo() != "0000001" ||
+		record.Value("INTPTLAT") != "+39.7684030" ||
+		record.Value("NAME") != "Doña Ana" {
+		t.Errorf("got %v", record.Values)
+	}
+
+	var lineError *LineError
+	if _, err := reader.Next(); !errors.As(err, &lineError) {
+		t.Fatalf("short record: got %v, want a *LineError", err)
+	}
+	if lineError.Line != 2 {
+		t.Errorf("short record error on line %d, want 2", lineError.Line)
+	}
+
+	record, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Value("INTPTLAT") != "+39768403" || record.Value("NAME") != "" {
+		t.Errorf("got %v", record.Values)
+	}
+	if GeoRecordLength(fields) != 28 {
+		t.Errorf("GeoRecordLength() = %d, want 28", GeoRecordLength(fields))
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("got %v after the last record, want io.EOF", err)
+	}
+}
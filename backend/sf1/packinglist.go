@@ -0,0 +1,87 @@
+package sf1
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+var DataLookupRegexp = regexp.MustCompile(`(.*)\|(\d\d*):(\d\d*)\|$`)
+var FileListRegexp = regexp.MustCompile(`^([a-z]{2}(?:geo|\d{5})2010\.sf1)\|`)
+
+// A table listed in a packing list.  ColumnOffset is the position of the
+// table's first column among its segment file's data columns (after the
+// five leading identification columns).
+type Table struct {
+	Name         string
+	FileNumber   int
+	ColumnOffset int
+	ColumnCount  int
+}
+
+// The geographic header and data segment files, and the tables, a packing
+// list describes.
+type PackingList struct {
+	Files  []string
+	Tables []Table
+}
+
+func ReadPackingList(r io.Reader) (*PackingList, error) {
+	packingList := new(PackingList)
+	fileOffsets := make(map[int]int)
+
+	scanner := bufio.NewScanner(r)
+	lineCount := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineCount++
+		if match := FileListRegexp.FindStringSubmatch(line); len(match) > 0 {
+			packingList.Files = append(packingList.Files, match[1])
+			continue
+		}
+		match := DataLookupRegexp.FindStringSubmatch(line)
+		if len(match) == 0 {
+			continue
+		}
+		fileNumber, err := strconv.ParseInt(match[2], 10, 32)
+		if err != nil {
+			return nil, &LineError{lineCount, fmt.Errorf(
+				"error parsing file number for table %s (%s)", match[1], err,
+			)}
+		}
+		columnCount, err := strconv.ParseInt(match[3], 10, 32)
+		if err != nil {
+			return nil, &LineError{lineCount, fmt.Errorf(
+				"error parsing column count for table %s (%s)", match[1], err,
+			)}
+		}
+		packingList.Tables = append(packingList.Tables, Table{
+			Name:         match[1],
+			FileNumber:   int(fileNumber),
+			ColumnOffset: fileOffsets[int(fileNumber)],
+			ColumnCount:  int(columnCount),
+		})
+		fileOffsets[int(fileNumber)] += int(columnCount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return packingList, nil
+}
+
+// Returns the number of fields each line of a data segment file must have
+// to hold all the tables the packing list places in it.
+func (packingList *PackingList) SegmentFieldCounts() map[int]int {
+	fieldCounts := make(map[int]int)
+	for _, table := range packingList.Tables {
+		fieldCount := SegmentKeyColumns + table.ColumnOffset + table.ColumnCount
+		if fieldCount > fieldCounts[table.FileNumber] {
+			fieldCounts[table.FileNumber] = fieldCount
+		}
+	}
+
+	return fieldCounts
+}
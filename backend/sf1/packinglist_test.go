@@ -0,0 +1,99 @@
+package sf1
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const packingList = `STATE: Indiana
+FILE NAME|DATE|SIZE|LINES
+ingeo2010.sf1|2011-06-16 10:42:05|155123500|318210
+in000012010.sf1|2011-06-16 10:42:05|25046500|318210
+in000022010.sf1|2011-06-16 10:42:05|80193200|318210
+
+Table Lookup
+p1|01:1|
+p2|01:6|
+p3|02:8|
+pct12a|02:209|
+`
+
+func TestReadPackingList(t *testing.T) {
+	tests := []struct {
+		name        string
+		packingList string
+		want        *PackingList
+	}{
+		{
+			name:        "files and tables",
+			packingList: packingList,
+			want: &PackingList{
+				Files: []string{
+					"ingeo2010.sf1", "in000012010.sf1", "in000022010.sf1",
+				},
+				Tables: []Table{
+					{Name: "p1", FileNumber: 1, ColumnOffset: 0, ColumnCount: 1},
+					{Name: "p2", FileNumber: 1, ColumnOffset: 1, ColumnCount: 6},
+					{Name: "p3", FileNumber: 2, ColumnOffset: 0, ColumnCount: 8},
+					{
+						Name: "pct12a", FileNumber: 2, ColumnOffset: 8,
+						ColumnCount: 209,
+					},
+				},
+			},
+		},
+		{
+			name:        "Windows line endings",
+			packingList: "ingeo2010.sf1|x\r\np1|01:1|\r\n",
+			want: &PackingList{
+				Files:  []string{"ingeo2010.sf1"},
+				Tables: []Table{{Name: "p1", FileNumber: 1, ColumnCount: 1}},
+			},
+		},
+		{
+			name:        "empty",
+			packingList: "",
+			want:        &PackingList{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReadPackingList(strings.NewReader(test.packingList))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadPackingListBadNumber(t *testing.T) {
+	_, err := ReadPackingList(strings.NewReader(
+		"p1|01:1|\np2|99999999999:6|\n",
+	))
+
+	var lineError *LineError
+	if !errors.As(err, &lineError) {
+		t.Fatalf("got %v, want a *LineError", err)
+	}
+	if lineError.Line != 2 {
+		t.Errorf("error on line %d, want 2", lineError.Line)
+	}
+}
+
+func TestSegmentFieldCounts(t *testing.T) {
+	list, err := ReadPackingList(strings.NewReader(packingList))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]int{1: 5 + 7, 2: 5 + 217}
+	if got := list.SegmentFieldCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
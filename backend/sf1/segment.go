@@ -0,0 +1,126 @@
+package sf1
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Every data segment line starts with FILEID, STUSAB, CHARITER, CIFSN and
+// LOGRECNO.
+const SegmentKeyColumns = 5
+
+var SegmentKeyColumnNames = []string{
+	"fileid", "stusab", "chariter", "cifsn", "logrecno",
+}
+
+// A line of a data segment file.
+type SegmentRow struct {
+	Line   int
+	Fields []string
+}
+
+func (row *SegmentRow) StUSAB() string {
+	return row.Fields[1]
+}
+
+func (row *SegmentRow) LogRecNo() string {
+	return row.Fields[4]
+}
+
+// Returns the five identification columns followed by the table's columns.
+func (row *SegmentRow) TableValues(table Table) ([]string, error) {
+	startIndex := SegmentKeyColumns + table.ColumnOffset
+	endIndex := startIndex + table.ColumnCount
+	if endIndex > len(row.Fields) {
+		return nil, &LineError{row.Line, fmt.Errorf(
+			"row value indices for %s out of range: [%d:%d] (%d)",
+			table.Name, startIndex, endIndex, len(row.Fields),
+		)}
+	}
+
+	values := make([]string, 0, SegmentKeyColumns+table.ColumnCount)
+	values = append(values, row.Fields[:SegmentKeyColumns]...)
+
+	return append(values, row.Fields[startIndex:endIndex]...), nil
+}
+
+// Reads the lines of a data segment file.
+type SegmentReader struct {
+	lines     *lineReader
+	delimiter string
+}
+
+func NewSegmentReader(r io.Reader) *SegmentReader {
+	return NewDelimitedSegmentReader(r, ",")
+}
+
+// Reads segment files laid out like SF1's but with another delimiter, such
+// as the pipe-delimited 2020 redistricting data files.
+func NewDelimitedSegmentReader(r io.Reader, delimiter string) *SegmentReader {
+	return &SegmentReader{lines: newLineReader(r), delimiter: delimiter}
+}
+
+// Returns the next row, or io.EOF after the last one.  A line without the
+// identification columns returns a *LineError; reading can continue past
+// it.
+func (sr *SegmentReader) Next() (*SegmentRow, error) {
+	line, lineNumber, err := sr.lines.next()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(line, sr.delimiter)
+	if len(fields) < SegmentKeyColumns {
+		return nil, &LineError{lineNumber, fmt.Errorf(
+			"%d fields, expected at least %d", len(fields), SegmentKeyColumns,
+		)}
+	}
+
+	return &SegmentRow{Line: lineNumber, Fields: fields}, nil
+}
+
+// A table's row: the identification columns and the table's columns.
+type TableRow struct {
+	Line     int
+	StUSAB   string
+	LogRecNo string
+	Values   []string
+}
+
+// Reads a single table's rows out of its data segment file.
+type TableReader struct {
+	Table    Table
+	segments *SegmentReader
+}
+
+func NewTableReader(r io.Reader, table Table) *TableReader {
+	return NewDelimitedTableReader(r, table, ",")
+}
+
+func NewDelimitedTableReader(r io.Reader, table Table, delimiter string) *TableReader {
+	return &TableReader{
+		Table:    table,
+		segments: NewDelimitedSegmentReader(r, delimiter),
+	}
+}
+
+// Returns the next row, or io.EOF after the last one.  A line too short for
+// the table returns a *LineError; reading can continue past it.
+func (tr *TableReader) Next() (*TableRow, error) {
+	row, err := tr.segments.Next()
+	if err != nil {
+		return nil, err
+	}
+	values, err := row.TableValues(tr.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TableRow{
+		Line:     row.Line,
+		StUSAB:   row.StUSAB(),
+		LogRecNo: row.LogRecNo(),
+		Values:   values,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package sf1
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmentReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		delimiter string
+		want      []*SegmentRow
+		errLines  []int
+	}{
+		{
+			name:      "rows",
+			data:      "SF1ST,IN,000,01,0000001,6483802,1\nSF1ST,IN,000,01,0000002,5,6\n",
+			delimiter: ",",
+			want: []*SegmentRow{
+				{Line: 1, Fields: []string{
+					"SF1ST", "IN", "000", "01", "0000001", "6483802", "1",
+				}},
+				{Line: 2, Fields: []string{
+					"SF1ST", "IN", "000", "01", "0000002", "5", "6",
+				}},
+			},
+		},
+		{
+			name:      "blank lines are skipped but counted",
+			data:      "\nSF1ST,IN,000,01,0000001,7\n\n\nSF1ST,IN,000,01,0000002,8",
+			delimiter: ",",
+			want: []*SegmentRow{
+				{Line: 2, Fields: []string{
+					"SF1ST", "IN", "000", "01", "0000001", "7",
+				}},
+				{Line: 5, Fields: []string{
+					"SF1ST", "IN", "000", "01", "0000002", "8",
+				}},
+			},
+		},
+		{
+			name:      "short lines are errors",
+			data:      "SF1ST,IN\nSF1ST,IN,000,01,0000002,8\n",
+			delimiter: ",",
+			want: []*SegmentRow{
+				{Line: 2, Fields: []string{
+					"SF1ST", "IN", "000", "01", "0000002", "8",
+				}},
+			},
+			errLines: []int{1},
+		},
+		{
+			name:      "pipe-delimited",
+			data:      "PLST|IN|000|01|0000001|6785528\n",
+			delimiter: "|",
+			want: []*SegmentRow{
+				{Line: 1, Fields: []string{
+					"PLST", "IN", "000", "01", "0000001", "6785528",
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewDelimitedSegmentReader(
+				strings.NewReader(test.data), test.delimiter,
+			)
+			rows := []*SegmentRow{}
+			errLines := []int{}
+			for {
+				row, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				var lineError *LineError
+				if errors.As(err, &lineError) {
+					errLines = append(errLines, lineError.Line)
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				rows = append(rows, row)
+			}
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("rows = %+v, want %+v", rows, test.want)
+			}
+			if len(errLines) != 0 || len(test.errLines) != 0 {
+				if !reflect.DeepEqual(errLines, test.errLines) {
+					t.Errorf("errors on lines %v, want %v",
+						errLines, test.errLines,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestTableReader(t *testing.T) {
+	data := "SF1ST,IN,000,01,0000001,6483802,1,2,3,4,5,6\n" +
+		"SF1ST,IN,000,01,0000002,10,11,12\n"
+	table := Table{Name: "p2", FileNumber: 1, ColumnOffset: 1, ColumnCount: 6}
+	reader := NewTableReader(strings.NewReader(data), table)
+
+	row, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &TableRow{
+		Line:     1,
+		StUSAB:   "IN",
+		LogRecNo: "0000001",
+		Values: []string{
+			"SF1ST", "IN", "000", "01", "0000001", "1", "2", "3", "4", "5", "6",
+		},
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("got %+v, want %+v", row, want)
+	}
+
+	var lineError *LineError
+	if _, err := reader.Next(); !errors.As(err, &lineError) {
+		t.Fatalf("short row: got %v, want a *LineError", err)
+	}
+	if lineError.Line != 2 {
+		t.Errorf("short row error on line %d, want 2", lineError.Line)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("got %v after the last row, want io.EOF", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package sf1 reads the Census Bureau's 2010 Summary File 1: packing lists,
+// fixed-width geographic header records and comma-separated data segment
+// files.  Readers return errors rather than exiting, and malformed lines are
+// reported as *LineError so callers can skip them and keep reading.
+package sf1
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const DataFileTemplate = "%s000%02d2010.sf1"
+const GeoFileTemplate = "%sgeo2010.sf1"
+const PackingListFileTemplate = "%s2010.sf1.prd.packinglist.txt"
+
+// USPS codes for which the Census Bureau publishes SF1 files.  "us" is the
+// national file.
+var States = map[string]string{
+	"al": "Alabama",
+	"ak": "Alaska",
+	"az": "Arizona",
+	"ar": "Arkansas",
+	"ca": "California",
+	"co": "Colorado",
+	"ct": "Connecticut",
+	"de": "Delaware",
+	"dc": "District of Columbia",
+	"fl": "Florida",
+	"ga": "Georgia",
+	"hi": "Hawaii",
+	"id": "Idaho",
+	"il": "Illinois",
+	"in": "Indiana",
+	"ia": "Iowa",
+	"ks": "Kansas",
+	"ky": "Kentucky",
+	"la": "Louisiana",
+	"me": "Maine",
+	"md": "Maryland",
+	"ma": "Massachusetts",
+	"mi": "Michigan",
+	"mn": "Minnesota",
+	"ms": "Mississippi",
+	"mo": "Missouri",
+	"mt": "Montana",
+	"ne": "Nebraska",
+	"nv": "Nevada",
+	"nh": "New Hampshire",
+	"nj": "New Jersey",
+	"nm": "New Mexico",
+	"ny": "New York",
+	"nc": "North Carolina",
+	"nd": "North Dakota",
+	"oh": "Ohio",
+	"ok": "Oklahoma",
+	"or": "Oregon",
+	"pa": "Pennsylvania",
+	"ri": "Rhode Island",
+	"sc": "South Carolina",
+	"sd": "South Dakota",
+	"tn": "Tennessee",
+	"tx": "Texas",
+	"ut": "Utah",
+	"vt": "Vermont",
+	"va": "Virginia",
+	"wa": "Washington",
+	"wv": "West Virginia",
+	"wi": "Wisconsin",
+	"wy": "Wyoming",
+	"pr": "Puerto Rico",
+	"us": "United States",
+}
+
+// A malformed line in a census file.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Returns the name of a state's data segment file, e.g. "in000012010.sf1".
+func DataFileName(state string, fileNumber int) string {
+	return fmt.Sprintf(DataFileTemplate, state, fileNumber)
+}
+
+// Returns the name of a state's geographic header file.
+func GeoFileName(state string) string {
+	return fmt.Sprintf(GeoFileTemplate, state)
+}
+
+// Returns the name of a state's packing list.
+func PackingListFileName(state string) string {
+	return fmt.Sprintf(PackingListFileTemplate, state)
+}
+
+// Reads the non-empty lines of a census file, counting all of them.
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	return &lineReader{scanner: scanner}
+}
+
+func (lr *lineReader) next() (string, int, error) {
+	for lr.scanner.Scan() {
+		lr.line++
+		if line := lr.scanner.Text(); len(line) > 0 {
+			return line, lr.line, nil
+		}
+	}
+	if err := lr.scanner.Err(); err != nil {
+		return "", lr.line, err
+	}
+
+	return "", lr.line, io.EOF
+}
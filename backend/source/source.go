@@ -0,0 +1,188 @@
+// Package source finds census data files, either unpacked in a folder or
+// straight from the zip archives the Census Bureau publishes (e.g.
+// in2010.sf1.zip).  Each file can be opened any number of times, and every
+// reader is independent of the others, so tables sharing a segment file can
+// read it at the same time.
+package source
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A census data file.  Path says where it was found, e.g.
+// data/in2010.sf1.zip:in000012010.sf1 for a file in an archive.
+type File struct {
+	Name string
+	Path string
+	Size int64
+
+	open     func() (io.ReadCloser, error)
+	archived bool
+}
+
+// Opens a new reader at the start of the file.
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.open()
+}
+
+// The files in a folder and in the zip archives inside it, or in a single
+// zip archive, by name.
+type Source struct {
+	Path string
+
+	files    map[string]*File
+	archives []*zip.ReadCloser
+}
+
+// Opens a folder or a zip archive.  A folder's own files take precedence
+// over those in its archives, so a partly unpacked archive still works; the
+// same file in two archives is an error.
+func Open(sourcePath string) (*Source, error) {
+	source := &Source{Path: sourcePath, files: make(map[string]*File)}
+
+	fileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if !fileInfo.IsDir() {
+		if !isArchive(sourcePath) {
+			return nil, fmt.Errorf("%s is neither a folder nor a zip archive",
+				sourcePath,
+			)
+		}
+		if err := source.addArchive(sourcePath); err != nil {
+			source.Close()
+			return nil, err
+		}
+		return source, nil
+	}
+
+	entries, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	archivePaths := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filePath := filepath.Join(sourcePath, entry.Name())
+		if isArchive(entry.Name()) {
+			archivePaths = append(archivePaths, filePath)
+			continue
+		}
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		source.files[entry.Name()] = &File{
+			Name: entry.Name(),
+			Path: filePath,
+			Size: entryInfo.Size(),
+			open: func() (io.ReadCloser, error) { return os.Open(filePath) },
+		}
+	}
+	for _, archivePath := range archivePaths {
+		if err := source.addArchive(archivePath); err != nil {
+			source.Close()
+			return nil, err
+		}
+	}
+
+	return source, nil
+}
+
+func isArchive(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".zip")
+}
+
+func (s *Source) addArchive(archivePath string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("error opening archive %s (%s)", archivePath, err)
+	}
+	s.archives = append(s.archives, archive)
+
+	for _, zipFile := range archive.File {
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+		zipFile := zipFile
+		name := path.Base(zipFile.Name)
+		if existing, ok := s.files[name]; ok {
+			if !existing.archived {
+				continue
+			}
+			return fmt.Errorf("%s is in both %s and %s",
+				name, existing.Path, archivePath,
+			)
+		}
+		s.files[name] = &File{
+			Name: name,
+			Path: archivePath + ":" + zipFile.Name,
+			Size: int64(zipFile.UncompressedSize64),
+			open: zipFile.Open,
+
+			archived: true,
+		}
+	}
+
+	return nil
+}
+
+// Returns the named file, or an error saying it's missing.
+func (s *Source) File(name string) (*File, error) {
+	file, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("no file %s in %s", name, s.Path)
+	}
+
+	return file, nil
+}
+
+// Returns the names of every file, sorted.
+func (s *Source) Names() []string {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *Source) Close() error {
+	var firstErr error
+
+	for _, archive := range s.archives {
+		if err := archive.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.archives = nil
+
+	return firstErr
+}
+
+// Census and TIGER files are Latin-1; values that aren't valid UTF-8 are
+// decoded as such.
+func DecodeLatin1(value string) string {
+	if utf8.ValidString(value) {
+		return value
+	}
+
+	runes := make([]rune, len(value))
+	for bi := 0; bi < len(value); bi++ {
+		runes[bi] = rune(value[bi])
+	}
+
+	return string(runes)
+}
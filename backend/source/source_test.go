@@ -0,0 +1,24 @@
+package source
+
+import (
+	"testing"
+)
+
+func TestDecodeLatin1(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Indianapolis", "Indianapolis"},
+		{"Do\xf1a Ana", "Doña Ana"},
+		{"Mayag\xfcez", "Mayagüez"},
+		{"Doña Ana", "Doña Ana"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := DecodeLatin1(test.value); got != test.want {
+			t.Errorf("DecodeLatin1(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
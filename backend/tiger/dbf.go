@@ -0,0 +1,115 @@
+package tiger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/camgunz/mapblue/backend/source"
+)
+
+// A column in a .dbf file.
+type Field struct {
+	Name   string
+	Type   byte
+	Length int
+}
+
+type dbfReader struct {
+	reader       *bufio.Reader
+	Fields       []Field
+	RecordCount  int
+	recordLength int
+	record       []byte
+}
+
+func newDBFReader(r io.Reader) (*dbfReader, error) {
+	dbf := &dbfReader{reader: bufio.NewReader(r)}
+	header := make([]byte, 32)
+
+	if _, err := io.ReadFull(dbf.reader, header); err != nil {
+		return nil, fmt.Errorf("error reading .dbf header (%s)", err)
+	}
+	dbf.RecordCount = int(binary.LittleEndian.Uint32(header[4:8]))
+	headerLength := int(binary.LittleEndian.Uint16(header[8:10]))
+	dbf.recordLength = int(binary.LittleEndian.Uint16(header[10:12]))
+
+	// Field descriptors follow the header, ending with 0x0D
+	bytesRead := 32
+	for {
+		descriptor := make([]byte, 32)
+		if _, err := io.ReadFull(dbf.reader, descriptor[:1]); err != nil {
+			return nil, fmt.Errorf("error reading .dbf fields (%s)", err)
+		}
+		bytesRead++
+		if descriptor[0] == 0x0D {
+			break
+		}
+		if _, err := io.ReadFull(dbf.reader, descriptor[1:]); err != nil {
+			return nil, fmt.Errorf("error reading .dbf fields (%s)", err)
+		}
+		bytesRead += 31
+
+		name := descriptor[:11]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		dbf.Fields = append(dbf.Fields, Field{
+			Name:   strings.TrimSpace(string(name)),
+			Type:   descriptor[11],
+			Length: int(descriptor[16]),
+		})
+	}
+
+	if headerLength > bytesRead {
+		skip := int64(headerLength - bytesRead)
+		if _, err := io.CopyN(io.Discard, dbf.reader, skip); err != nil {
+			return nil, fmt.Errorf("error reading .dbf header (%s)", err)
+		}
+	}
+
+	fieldLength := 1 // deletion flag
+	for _, field := range dbf.Fields {
+		fieldLength += field.Length
+	}
+	if fieldLength != dbf.recordLength {
+		return nil, fmt.Errorf(
+			".dbf fields are %d bytes long but records are %d",
+			fieldLength, dbf.recordLength,
+		)
+	}
+	dbf.record = make([]byte, dbf.recordLength)
+
+	return dbf, nil
+}
+
+// Reads the next record's values, keyed by field name.  Deleted records are
+// returned with deleted set so the caller stays in step with the .shp file.
+func (dbf *dbfReader) next() (values map[string]string, deleted bool, err error) {
+	if _, err := io.ReadFull(dbf.reader, dbf.record); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, false, fmt.Errorf("truncated .dbf record")
+		}
+		return nil, false, err
+	}
+
+	values = make(map[string]string, len(dbf.Fields))
+	offset := 1
+	for _, field := range dbf.Fields {
+		raw := dbf.record[offset : offset+field.Length]
+		values[field.Name] = decodeDBFValue(raw)
+		offset += field.Length
+	}
+
+	return values, dbf.record[0] == '*', nil
+}
+
+// TIGER .dbf files are Latin-1 unless they happen to be valid UTF-8.
+func decodeDBFValue(raw []byte) string {
+	return source.DecodeLatin1(
+		string(bytes.TrimSpace(bytes.TrimRight(raw, "\x00"))),
+	)
+}
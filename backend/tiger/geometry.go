@@ -0,0 +1,105 @@
+package tiger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+const ewkbMultiPolygon = 6
+const ewkbPolygon = 3
+const ewkbSRIDFlag = 0x20000000
+
+// A polygon's first ring is its exterior; the rest are holes.
+type Polygon [][]Point
+
+// Twice the ring's signed area; negative for clockwise rings.
+func signedArea(ring []Point) float64 {
+	area := 0.0
+	for pi := range ring {
+		next := ring[(pi+1)%len(ring)]
+		area += (ring[pi].X * next.Y) - (next.X * ring[pi].Y)
+	}
+
+	return area
+}
+
+func ringContains(ring []Point, point Point) bool {
+	inside := false
+	for pi, pj := 0, len(ring)-1; pi < len(ring); pj, pi = pi, pi+1 {
+		a, b := ring[pi], ring[pj]
+		if (a.Y > point.Y) != (b.Y > point.Y) &&
+			point.X < (b.X-a.X)*(point.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// Groups shapefile rings into polygons.  Shapefile exteriors are clockwise
+// and holes counterclockwise; each hole goes to the first exterior that
+// contains it, and holes with no exterior are kept as polygons of their own.
+func buildPolygons(rings [][]Point) []Polygon {
+	polygons := []Polygon{}
+	holes := [][]Point{}
+
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			continue
+		}
+		if signedArea(ring) <= 0 {
+			polygons = append(polygons, Polygon{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	for _, hole := range holes {
+		placed := false
+		for pi, polygon := range polygons {
+			if ringContains(polygon[0], hole[0]) {
+				polygons[pi] = append(polygon, hole)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			polygons = append(polygons, Polygon{hole})
+		}
+	}
+
+	return polygons
+}
+
+// Encodes polygons as a hex EWKB MULTIPOLYGON, which PostGIS accepts as
+// geometry input.
+func hexEWKBMultiPolygon(polygons []Polygon, srid int) string {
+	buf := new(bytes.Buffer)
+
+	writeUint32 := func(value uint32) {
+		binary.Write(buf, binary.LittleEndian, value)
+	}
+	writeFloat64 := func(value float64) {
+		binary.Write(buf, binary.LittleEndian, value)
+	}
+
+	buf.WriteByte(1)
+	writeUint32(ewkbMultiPolygon | ewkbSRIDFlag)
+	writeUint32(uint32(srid))
+	writeUint32(uint32(len(polygons)))
+	for _, polygon := range polygons {
+		buf.WriteByte(1)
+		writeUint32(ewkbPolygon)
+		writeUint32(uint32(len(polygon)))
+		for _, ring := range polygon {
+			writeUint32(uint32(len(ring)))
+			for _, point := range ring {
+				writeFloat64(point.X)
+				writeFloat64(point.Y)
+			}
+		}
+	}
+
+	return hex.EncodeToString(buf.Bytes())
+}
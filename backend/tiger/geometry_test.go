@@ -0,0 +1,92 @@
+package tiger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A counterclockwise (hole) square.
+func hole(x float64, y float64, size float64) []Point {
+	ring := square(x, y, size)
+	for pi, pj := 0, len(ring)-1; pi < pj; pi, pj = pi+1, pj-1 {
+		ring[pi], ring[pj] = ring[pj], ring[pi]
+	}
+
+	return ring
+}
+
+func TestBuildPolygons(t *testing.T) {
+	tests := []struct {
+		name  string
+		rings [][]Point
+		want  []Polygon
+	}{
+		{
+			name:  "one exterior",
+			rings: [][]Point{square(0, 0, 10)},
+			want:  []Polygon{{square(0, 0, 10)}},
+		},
+		{
+			name:  "exterior with a hole",
+			rings: [][]Point{square(0, 0, 10), hole(2, 2, 2)},
+			want:  []Polygon{{square(0, 0, 10), hole(2, 2, 2)}},
+		},
+		{
+			name: "holes go to the exterior containing them",
+			rings: [][]Point{
+				square(0, 0, 10), hole(22, 2, 2), square(20, 0, 10),
+				hole(2, 2, 2),
+			},
+			want: []Polygon{
+				{square(0, 0, 10), hole(2, 2, 2)},
+				{square(20, 0, 10), hole(22, 2, 2)},
+			},
+		},
+		{
+			name:  "a hole without an exterior is kept",
+			rings: [][]Point{square(0, 0, 10), hole(40, 40, 2)},
+			want:  []Polygon{{square(0, 0, 10)}, {hole(40, 40, 2)}},
+		},
+		{
+			name: "degenerate rings are dropped",
+			rings: [][]Point{
+				{{0, 0}, {1, 1}, {0, 0}}, square(0, 0, 10),
+			},
+			want: []Polygon{{square(0, 0, 10)}},
+		},
+		{
+			name:  "no rings",
+			rings: nil,
+			want:  []Polygon{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := buildPolygons(test.rings)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHexEWKBMultiPolygon(t *testing.T) {
+	polygons := []Polygon{{square(0, 0, 1), hole(0.25, 0.25, 0.5)}}
+
+	got := hexEWKBMultiPolygon(polygons, SRID_NAD83)
+
+	// Little endian, MULTIPOLYGON with an SRID, 4269, one polygon
+	prefix := "01" + "06000020" + "ad100000" + "01000000"
+	// Little endian, POLYGON, two rings, the first with five points
+	polygonPrefix := "01" + "03000000" + "02000000" + "05000000"
+	if !strings.HasPrefix(got, prefix+polygonPrefix) {
+		t.Errorf("got %s, want it to start with %s", got, prefix+polygonPrefix)
+	}
+	// Headers, ring point counts, then ten points of two doubles
+	wantLength := 2 * (9 + 4 + 9 + 4 + 4 + 10*16)
+	if len(got) != wantLength {
+		t.Errorf("got %d hex digits, want %d", len(got), wantLength)
+	}
+}
@@ -0,0 +1,221 @@
+// Package tiger reads Census Bureau TIGER/Line polygon shapefiles, either
+// unpacked on disk or straight from the zip archives the Bureau publishes.
+package tiger
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The SRID of NAD83 geographic coordinates, which every TIGER/Line file uses.
+const SRID_NAD83 = 4269
+
+// A shapefile record: its attributes from the .dbf file and its polygons.
+type Record struct {
+	Number     int
+	Attributes map[string]string
+	Polygons   []Polygon
+}
+
+// Reads the records of a polygon shapefile in order.
+type Reader struct {
+	Path   string
+	Fields []Field
+	SRID   int
+
+	shp     *shpReader
+	dbf     *dbfReader
+	closers []io.Closer
+	count   int
+}
+
+// Opens a shapefile, given the path to its .shp file or to a zip archive
+// holding the .shp, .dbf and .prj files.
+func Open(path string) (*Reader, error) {
+	reader := &Reader{Path: path}
+
+	var shpFile, dbfFile, prjFile io.ReadCloser
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		shpFile, dbfFile, prjFile, err = reader.openZip(path)
+	} else {
+		shpFile, dbfFile, prjFile, err = reader.openFiles(path)
+	}
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	if reader.SRID, err = readSRID(prjFile); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if reader.shp, err = newSHPReader(shpFile); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if reader.dbf, err = newDBFReader(dbfFile); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	reader.Fields = reader.dbf.Fields
+
+	return reader, nil
+}
+
+func (r *Reader) openFiles(shpPath string) (io.ReadCloser, io.ReadCloser, io.ReadCloser, error) {
+	basePath := strings.TrimSuffix(shpPath, filepath.Ext(shpPath))
+	files := make([]io.ReadCloser, 3)
+
+	for fi, extension := range []string{".shp", ".dbf", ".prj"} {
+		file, err := os.Open(basePath + extension)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		r.closers = append(r.closers, file)
+		files[fi] = file
+	}
+
+	return files[0], files[1], files[2], nil
+}
+
+func (r *Reader) openZip(zipPath string) (io.ReadCloser, io.ReadCloser, io.ReadCloser, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r.closers = append(r.closers, archive)
+
+	files := make([]io.ReadCloser, 3)
+	for fi, extension := range []string{".shp", ".dbf", ".prj"} {
+		for _, zipFile := range archive.File {
+			if !strings.EqualFold(filepath.Ext(zipFile.Name), extension) {
+				continue
+			}
+			file, err := zipFile.Open()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			r.closers = append(r.closers, file)
+			files[fi] = file
+			break
+		}
+		if files[fi] == nil {
+			return nil, nil, nil, fmt.Errorf(
+				"%s has no %s file", zipPath, extension,
+			)
+		}
+	}
+
+	return files[0], files[1], files[2], nil
+}
+
+// TIGER/Line files are unprojected NAD83; anything else would need to be
+// reprojected, which this package doesn't do.
+func readSRID(prjFile io.Reader) (int, error) {
+	prj, err := io.ReadAll(prjFile)
+	if err != nil {
+		return 0, fmt.Errorf("error reading .prj file (%s)", err)
+	}
+	wkt := string(prj)
+	if strings.HasPrefix(wkt, "PROJCS") ||
+		!strings.Contains(wkt, "North_American_1983") {
+		return 0, fmt.Errorf("unsupported projection %s", wkt)
+	}
+
+	return SRID_NAD83, nil
+}
+
+// Returns the next record, or io.EOF after the last one.  Deleted records
+// are skipped.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		rings, err := r.shp.next()
+		if err == io.EOF {
+			if r.count < r.dbf.RecordCount {
+				return nil, fmt.Errorf(
+					"%s: .shp file ended after %d of %d records",
+					r.Path, r.count, r.dbf.RecordCount,
+				)
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", r.Path, err)
+		}
+
+		attributes, deleted, err := r.dbf.next()
+		if err == io.EOF {
+			return nil, fmt.Errorf(
+				"%s: .dbf file has fewer records than the .shp file", r.Path,
+			)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", r.Path, err)
+		}
+		r.count++
+		if deleted {
+			continue
+		}
+
+		return &Record{
+			Number:     r.count,
+			Attributes: attributes,
+			Polygons:   buildPolygons(rings),
+		}, nil
+	}
+}
+
+// Returns the value of the first attribute whose name starts with prefix,
+// so "GEOID" finds TIGER 2010's GEOID10 and TIGER 2000's GEOID00 alike.
+func (record *Record) Attribute(prefix string) string {
+	if value, ok := record.Attributes[prefix]; ok {
+		return value
+	}
+	for name, value := range record.Attributes {
+		suffix := strings.TrimPrefix(name, prefix)
+		if suffix != name && strings.Trim(suffix, "0123456789") == "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Returns the value of the named attribute for a TIGER/Line vintage, e.g.
+// GEOID20 for "GEOID" in 2020, falling back to any vintage's.
+func (record *Record) VintageAttribute(name string, vintage int) string {
+	vintageName := fmt.Sprintf("%s%02d", name, vintage%100)
+	if value, ok := record.Attributes[vintageName]; ok {
+		return value
+	}
+
+	return record.Attribute(name)
+}
+
+// The number of records the .dbf header says the shapefile holds.
+func (r *Reader) RecordCount() int {
+	return r.dbf.RecordCount
+}
+
+// Encodes the record's polygons as hex EWKB in the reader's SRID.
+func (r *Reader) HexEWKB(record *Record) string {
+	return hexEWKBMultiPolygon(record.Polygons, r.SRID)
+}
+
+func (r *Reader) Close() error {
+	var firstErr error
+
+	for ci := len(r.closers) - 1; ci >= 0; ci-- {
+		if err := r.closers[ci].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.closers = nil
+
+	return firstErr
+}
@@ -0,0 +1,437 @@
+package tiger
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nad83PRJ = `GEOGCS["GCS_North_American_1983",DATUM["D_North_American_1983",` +
+	`SPHEROID["GRS_1980",6378137,298.257222101]],PRIMEM["Greenwich",0],` +
+	`UNIT["Degree",0.017453292519943295]]`
+
+// Builds a .dbf file with character fields.
+func buildDBF(fields []Field, records [][]string) []byte {
+	buf := new(bytes.Buffer)
+	recordLength := 1
+	for _, field := range fields {
+		recordLength += field.Length
+	}
+
+	header := make([]byte, 32)
+	header[0] = 3
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(records)))
+	binary.LittleEndian.PutUint16(header[8:], uint16(32+len(fields)*32+1))
+	binary.LittleEndian.PutUint16(header[10:], uint16(recordLength))
+	buf.Write(header)
+	for _, field := range fields {
+		descriptor := make([]byte, 32)
+		copy(descriptor, field.Name)
+		descriptor[11] = 'C'
+		descriptor[16] = byte(field.Length)
+		buf.Write(descriptor)
+	}
+	buf.WriteByte(0x0D)
+	for _, record := range records {
+		buf.WriteByte(' ')
+		for fi, field := range fields {
+			value := make([]byte, field.Length)
+			for vi := range value {
+				value[vi] = ' '
+			}
+			copy(value, record[fi])
+			buf.Write(value)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Builds a polygon .shp file, each record made of the given rings.  Records
+// without rings are null shapes.
+func buildSHP(records [][][]Point) []byte {
+	body := new(bytes.Buffer)
+	for ri, rings := range records {
+		content := new(bytes.Buffer)
+		if len(rings) == 0 {
+			binary.Write(content, binary.LittleEndian, uint32(shpNullShape))
+			binary.Write(body, binary.BigEndian, uint32(ri+1))
+			binary.Write(body, binary.BigEndian, uint32(content.Len()/2))
+			body.Write(content.Bytes())
+			continue
+		}
+		pointCount := 0
+		for _, ring := range rings {
+			pointCount += len(ring)
+		}
+		binary.Write(content, binary.LittleEndian, uint32(shpPolygon))
+		binary.Write(content, binary.LittleEndian, [4]float64{})
+		binary.Write(content, binary.LittleEndian, uint32(len(rings)))
+		binary.Write(content, binary.LittleEndian, uint32(pointCount))
+		start := 0
+		for _, ring := range rings {
+			binary.Write(content, binary.LittleEndian, uint32(start))
+			start += len(ring)
+		}
+		for _, ring := range rings {
+			for _, point := range ring {
+				binary.Write(content, binary.LittleEndian, point.X)
+				binary.Write(content, binary.LittleEndian, point.Y)
+			}
+		}
+
+		binary.Write(body, binary.BigEndian, uint32(ri+1))
+		binary.Write(body, binary.BigEndian, uint32(content.Len()/2))
+		body.Write(content.Bytes())
+	}
+
+	header := make([]byte, 100)
+	binary.BigEndian.PutUint32(header[0:], shpFileCode)
+	binary.BigEndian.PutUint32(header[24:], uint32((100+body.Len())/2))
+	binary.LittleEndian.PutUint32(header[28:], 1000)
+	binary.LittleEndian.PutUint32(header[32:], shpPolygon)
+
+	return append(header, body.Bytes()...)
+}
+
+// Writes a shapefile to a temporary folder, returning its .shp path.
+func writeShapefile(t *testing.T, fields []Field, records [][]string, shapes [][][]Point) string {
+	t.Helper()
+	shpPath := filepath.Join(t.TempDir(), "tl_tabblock.shp")
+	basePath := shpPath[:len(shpPath)-len(".shp")]
+	files := map[string][]byte{
+		".shp": buildSHP(shapes),
+		".dbf": buildDBF(fields, records),
+		".prj": []byte(nad83PRJ),
+	}
+	for extension, data := range files {
+		if err := os.WriteFile(basePath+extension, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return shpPath
+}
+
+// A clockwise (exterior) square.
+func square(x float64, y float64, size float64) []Point {
+	return []Point{
+		{x, y}, {x, y + size}, {x + size, y + size}, {x + size, y}, {x, y},
+	}
+}
+
+func TestVintageAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		vintage int
+		fields  []string
+	}{
+		{
+			name:    "tabblock10",
+			vintage: 2010,
+			fields: []string{
+				"STATEFP10", "COUNTYFP10", "TRACTCE10", "BLOCKCE10",
+				"GEOID10", "NAME10", "INTPTLAT10", "INTPTLON10",
+			},
+		},
+		{
+			name:    "tabblock20",
+			vintage: 2020,
+			fields: []string{
+				"STATEFP20", "COUNTYFP20", "TRACTCE20", "BLOCKCE20",
+				"GEOID20", "GEOIDFQ20", "NAME20", "INTPTLAT20", "INTPTLON20",
+			},
+		},
+		{
+			name:    "tabblock20 read for 2010",
+			vintage: 2010,
+			fields: []string{
+				"STATEFP20", "COUNTYFP20", "TRACTCE20", "BLOCKCE20",
+				"GEOID20", "NAME20", "INTPTLAT20", "INTPTLON20",
+			},
+		},
+	}
+	want := map[string]string{
+		"STATEFP":  "18",
+		"COUNTYFP": "097",
+		"TRACTCE":  "350500",
+		"BLOCKCE":  "1001",
+		"GEOID":    "180973505001001",
+		"GEOIDFQ":  "1000000US180973505001001",
+		"NAME":     "Block 1001",
+		"INTPTLAT": "+39.7684030",
+		"INTPTLON": "-086.1580680",
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields := []Field{}
+			values := []string{}
+			for _, name := range test.fields {
+				prefix := name[:len(name)-2]
+				fields = append(fields, Field{Name: name, Length: 24})
+				values = append(values, want[prefix])
+			}
+			shpPath := writeShapefile(t, fields, [][]string{values},
+				[][][]Point{{square(-86.16, 39.76, 0.01)}},
+			)
+
+			reader, err := Open(shpPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if reader.SRID != SRID_NAD83 {
+				t.Errorf("SRID = %d, want %d", reader.SRID, SRID_NAD83)
+			}
+			record, err := reader.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{
+				"STATEFP", "COUNTYFP", "TRACTCE", "BLOCKCE", "GEOID", "NAME",
+				"INTPTLAT", "INTPTLON",
+			} {
+				got := record.VintageAttribute(name, test.vintage)
+				if got != want[name] {
+					t.Errorf("%s = %q, want %q", name, got, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestVintageAttributePrefersVintage(t *testing.T) {
+	record := &Record{Attributes: map[string]string{
+		"GEOID10": "180973505001001",
+		"GEOID20": "180973505002002",
+	}}
+
+	if got := record.VintageAttribute("GEOID", 2010); got != "180973505001001" {
+		t.Errorf("2010 GEOID = %q", got)
+	}
+	if got := record.VintageAttribute("GEOID", 2020); got != "180973505002002" {
+		t.Errorf("2020 GEOID = %q", got)
+	}
+	if got := record.VintageAttribute("ALAND", 2020); got != "" {
+		t.Errorf("missing ALAND = %q", got)
+	}
+}
+
+// Marks a record of a .dbf file built by buildDBF as deleted.
+func deleteDBFRecord(dbf []byte, fields []Field, index int) {
+	recordLength := 1
+	for _, field := range fields {
+		recordLength += field.Length
+	}
+	dbf[32+len(fields)*32+1+index*recordLength] = '*'
+}
+
+func TestReaderRecords(t *testing.T) {
+	fields := []Field{
+		{Name: "GEOID10", Length: 15},
+		{Name: "NAME10", Length: 10},
+	}
+	records := [][]string{
+		{"180973505001001", "Block 1001"},
+		{"180973505001002", "Block 1002"},
+		{"180973505001003", "Block 1003"},
+		{"350280001001004", "Ca\xf1on"},
+	}
+	shapes := [][][]Point{
+		{square(0, 0, 1)},
+		nil,
+		{square(2, 0, 1)},
+		{square(4, 0, 1), hole(4.25, 0.25, 0.5)},
+	}
+	dbf := buildDBF(fields, records)
+	deleteDBFRecord(dbf, fields, 2)
+
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"tl_tabblock.shp": buildSHP(shapes),
+		"tl_tabblock.dbf": dbf,
+		"tl_tabblock.prj": []byte(nad83PRJ),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zipPath := filepath.Join(tempDir, "tl_tabblock.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(zipFile)
+	for name, data := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipFile.Close()
+
+	want := []*Record{
+		{
+			Number: 1,
+			Attributes: map[string]string{
+				"GEOID10": "180973505001001", "NAME10": "Block 1001",
+			},
+			Polygons: []Polygon{{square(0, 0, 1)}},
+		},
+		{
+			Number: 2,
+			Attributes: map[string]string{
+				"GEOID10": "180973505001002", "NAME10": "Block 1002",
+			},
+			Polygons: []Polygon{},
+		},
+		{
+			Number: 4,
+			Attributes: map[string]string{
+				"GEOID10": "350280001001004", "NAME10": "Cañon",
+			},
+			Polygons: []Polygon{{square(4, 0, 1), hole(4.25, 0.25, 0.5)}},
+		},
+	}
+
+	for _, shpPath := range []string{
+		filepath.Join(tempDir, "tl_tabblock.shp"), zipPath,
+	} {
+		t.Run(filepath.Ext(shpPath), func(t *testing.T) {
+			reader, err := Open(shpPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if reader.RecordCount() != len(records) {
+				t.Errorf("RecordCount() = %d, want %d",
+					reader.RecordCount(), len(records),
+				)
+			}
+			if !reflect.DeepEqual(reader.Fields, []Field{
+				{Name: "GEOID10", Type: 'C', Length: 15},
+				{Name: "NAME10", Type: 'C', Length: 10},
+			}) {
+				t.Errorf("Fields = %v", reader.Fields)
+			}
+
+			got := []*Record{}
+			for {
+				record, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, record)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	fields := []Field{{Name: "GEOID10", Length: 15}}
+	records := [][]string{{"180973505001001"}}
+	shapes := [][][]Point{{square(0, 0, 1)}}
+
+	tests := []struct {
+		name   string
+		change func(shp []byte, dbf []byte) ([]byte, []byte, string)
+		err    string
+	}{
+		{
+			name: "projected",
+			change: func(shp []byte, dbf []byte) ([]byte, []byte, string) {
+				return shp, dbf, `PROJCS["NAD_1983_UTM_Zone_16N"]`
+			},
+			err: "unsupported projection",
+		},
+		{
+			name: "not a shapefile",
+			change: func(shp []byte, dbf []byte) ([]byte, []byte, string) {
+				binary.BigEndian.PutUint32(shp, 1234)
+				return shp, dbf, nad83PRJ
+			},
+			err: "not a shapefile",
+		},
+		{
+			name: "points",
+			change: func(shp []byte, dbf []byte) ([]byte, []byte, string) {
+				binary.LittleEndian.PutUint32(shp[32:], 1)
+				return shp, dbf, nad83PRJ
+			},
+			err: "unsupported shape type 1",
+		},
+		{
+			name: "dbf record length",
+			change: func(shp []byte, dbf []byte) ([]byte, []byte, string) {
+				binary.LittleEndian.PutUint16(dbf[10:], 20)
+				return shp, dbf, nad83PRJ
+			},
+			err: ".dbf fields are 16 bytes long but records are 20",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shp, dbf, prj := test.change(
+				buildSHP(shapes), buildDBF(fields, records),
+			)
+			basePath := filepath.Join(t.TempDir(), "tl_tabblock")
+			files := map[string][]byte{
+				".shp": shp, ".dbf": dbf, ".prj": []byte(prj),
+			}
+			for extension, data := range files {
+				if err := os.WriteFile(basePath+extension, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reader, err := Open(basePath + ".shp")
+			if err == nil {
+				reader.Close()
+				t.Fatalf("got no error, want %q", test.err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestReaderCountMismatch(t *testing.T) {
+	fields := []Field{{Name: "GEOID10", Length: 15}}
+	shpPath := writeShapefile(t, fields,
+		[][]string{{"180973505001001"}, {"180973505001002"}},
+		[][][]Point{{square(0, 0, 1)}},
+	)
+
+	reader, err := Open(shpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Next()
+	if err == nil || !strings.Contains(err.Error(), "ended after 1 of 2") {
+		t.Errorf("got %v, want a truncated .shp error", err)
+	}
+}
@@ -0,0 +1,122 @@
+package tiger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const shpFileCode = 9994
+const shpNullShape = 0
+const shpPolygon = 5
+const shpPolygonZ = 15
+const shpPolygonM = 25
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+type shpReader struct {
+	reader    *bufio.Reader
+	ShapeType int
+}
+
+func newSHPReader(r io.Reader) (*shpReader, error) {
+	shp := &shpReader{reader: bufio.NewReader(r)}
+	header := make([]byte, 100)
+
+	if _, err := io.ReadFull(shp.reader, header); err != nil {
+		return nil, fmt.Errorf("error reading .shp header (%s)", err)
+	}
+	if fileCode := binary.BigEndian.Uint32(header[0:4]); fileCode != shpFileCode {
+		return nil, fmt.Errorf("not a shapefile (file code %d)", fileCode)
+	}
+	shp.ShapeType = int(binary.LittleEndian.Uint32(header[32:36]))
+	switch shp.ShapeType {
+	case shpPolygon, shpPolygonZ, shpPolygonM:
+	default:
+		return nil, fmt.Errorf(
+			"unsupported shape type %d, expected polygons", shp.ShapeType,
+		)
+	}
+
+	return shp, nil
+}
+
+// Reads the next record's rings.  Null shapes have no rings.
+func (shp *shpReader) next() ([][]Point, error) {
+	recordHeader := make([]byte, 8)
+	if _, err := io.ReadFull(shp.reader, recordHeader); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated .shp record header")
+		}
+		return nil, err
+	}
+
+	// Content length is in 16-bit words
+	contentLength := int(binary.BigEndian.Uint32(recordHeader[4:8])) * 2
+	if contentLength < 4 {
+		return nil, fmt.Errorf("invalid .shp record length %d", contentLength)
+	}
+	content := make([]byte, contentLength)
+	if _, err := io.ReadFull(shp.reader, content); err != nil {
+		return nil, fmt.Errorf("truncated .shp record (%s)", err)
+	}
+
+	shapeType := int(binary.LittleEndian.Uint32(content[0:4]))
+	if shapeType == shpNullShape {
+		return nil, nil
+	}
+	if shapeType != shp.ShapeType {
+		return nil, fmt.Errorf(
+			"record shape type %d doesn't match file shape type %d",
+			shapeType, shp.ShapeType,
+		)
+	}
+	if contentLength < 44 {
+		return nil, fmt.Errorf("truncated .shp polygon record")
+	}
+
+	// Skip the shape type and bounding box
+	partCount := int(binary.LittleEndian.Uint32(content[36:40]))
+	pointCount := int(binary.LittleEndian.Uint32(content[40:44]))
+	partsOffset := 44
+	pointsOffset := partsOffset + (partCount * 4)
+	if partCount < 1 || pointsOffset+(pointCount*16) > contentLength {
+		return nil, fmt.Errorf(
+			"polygon record with %d parts and %d points overruns its length",
+			partCount, pointCount,
+		)
+	}
+
+	partStarts := make([]int, partCount+1)
+	for pi := 0; pi < partCount; pi++ {
+		offset := partsOffset + (pi * 4)
+		partStarts[pi] = int(binary.LittleEndian.Uint32(content[offset:]))
+	}
+	partStarts[partCount] = pointCount
+
+	rings := make([][]Point, partCount)
+	for pi := 0; pi < partCount; pi++ {
+		start, end := partStarts[pi], partStarts[pi+1]
+		if start < 0 || start > end || end > pointCount {
+			return nil, fmt.Errorf("invalid polygon part offset %d", start)
+		}
+		ring := make([]Point, end-start)
+		for ri := range ring {
+			offset := pointsOffset + ((start + ri) * 16)
+			ring[ri].X = math.Float64frombits(
+				binary.LittleEndian.Uint64(content[offset:]),
+			)
+			ring[ri].Y = math.Float64frombits(
+				binary.LittleEndian.Uint64(content[offset+8:]),
+			)
+		}
+		rings[pi] = ring
+	}
+
+	return rings, nil
+}
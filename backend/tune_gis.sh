@@ -8,14 +8,7 @@ do
 done
 
 ## Create indices
-psql -U postgres -d census -c 'CREATE UNIQUE INDEX idx_p11_logrecno ON p11 (logrecno);'
-psql -U postgres -d census -c 'CREATE UNIQUE INDEX idx_p16_logrecno ON p16 (logrecno);'
-psql -U postgres -d census -c 'CREATE UNIQUE INDEX idx_p19_logrecno ON p19 (logrecno);'
-psql -U postgres -d census -c 'CREATE UNIQUE INDEX idx_p29_logrecno ON p29 (logrecno);'
-psql -U postgres -d census -c \
-    'CREATE INDEX idx_geo_locations_intptlat ON geo_locations (intptlat);'
-psql -U postgres -d census -c \
-    'CREATE INDEX idx_geo_locations_intptlon ON geo_locations (intptlon);'
+## (load_census_data indexes geo_locations and the census data tables itself)
 psql -U postgres -d census -c \
     'CREATE UNIQUE INDEX idx_tabblock_intptlat ON tabblock (intptlat);'
 psql -U postgres -d census -c \